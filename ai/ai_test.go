@@ -2,6 +2,7 @@ package ai
 
 import (
 	"github.com/g3force/Go_Sokoban/engine"
+	"github.com/g3force/Go_Sokoban/internal/testlevel"
 	"testing"
 )
 
@@ -64,3 +65,215 @@ func TestClonePath(t *testing.T) {
 		t.Error("Clone failed (2)")
 	}
 }
+
+func TestSolvePushOptimal(t *testing.T) {
+	e := engine.NewEngine()
+	testlevel.Load(t, &e, testlevel.Corridor)
+	sol, found := SolvePushOptimal(e, 0)
+	if !found {
+		t.Fatal("no solution found")
+	}
+	if len(sol.Pushes) != 2 {
+		t.Errorf("expected 2 pushes, got %d", len(sol.Pushes))
+	}
+	for _, dir := range sol.Moves {
+		e.Move(dir)
+	}
+	if !e.Won() {
+		t.Error("moves do not solve the level")
+	}
+}
+
+func TestEstimateDifficulty(t *testing.T) {
+	e := engine.NewEngine()
+	testlevel.Load(t, &e, testlevel.Corridor)
+	d, solved := EstimateDifficulty(e, 0)
+	if !solved {
+		t.Fatal("level not solved")
+	}
+	if d.Pushes != 2 || d.BoxChanges != 0 {
+		t.Errorf("unexpected metrics: %d pushes, %d box changes", d.Pushes, d.BoxChanges)
+	}
+	if d.DeadRatio <= 0 || d.Score <= 0 {
+		t.Errorf("unexpected dead ratio %f or score %f", d.DeadRatio, d.Score)
+	}
+}
+
+func TestSolveMCTS(t *testing.T) {
+	e := engine.NewEngine()
+	testlevel.Load(t, &e, "########\n#      #\n# $@ . #\n# .  $ #\n#      #\n########")
+	c := NewMCTSConfig()
+	c.Iterations = 10000
+	sol, won := SolveMCTS(e, c)
+	if !won {
+		t.Fatal("no solution found")
+	}
+	for _, dir := range sol.Moves {
+		e.Move(dir)
+	}
+	if !e.Won() {
+		t.Error("moves do not solve the level")
+	}
+}
+
+func TestSolveMCTSShortest(t *testing.T) {
+	e := engine.NewEngine()
+	testlevel.Load(t, &e, testlevel.Corridor)
+	c := NewMCTSConfig()
+	c.Iterations = 1000
+	sol, won := SolveMCTS(e, c)
+	if lurd, _ := e.FormatLURD(sol.Moves); !won || len(sol.Pushes) != 2 {
+		t.Errorf("expected a solution with 2 pushes, got %q", lurd)
+	}
+
+	// a box pushed forth and back again
+	board := engine.NewBoard(staticSurface(e.Surface))
+	box := engine.NewPoint(2, 2)
+	pushes := []Push{{box, 0}, {engine.NewPoint(3, 2), 2}, {box, 0}, {engine.NewPoint(3, 2), 0}}
+	if remaining := removeCycles(board, []engine.Point{box}, e.FigPos(), pushes); len(remaining) != 2 || remaining[0] != pushes[2] {
+		t.Errorf("unexpected pushes %v", remaining)
+	}
+}
+
+func TestSolvePushOptimalSymmetric(t *testing.T) {
+	e := engine.NewEngine()
+	testlevel.Load(t, &e, testlevel.Microban(t, 95))
+	sol, found := SolvePushOptimal(e, 0)
+	if !found {
+		t.Fatal("no solution found")
+	}
+	for _, dir := range sol.Moves {
+		e.Move(dir)
+	}
+	if !e.Won() {
+		t.Error("moves do not solve the level")
+	}
+}
+
+// Microban level 95 with its dead fields and two pushed boxes
+func benchmarkPosition(tb testing.TB) (engine.Surface, []engine.Point, engine.Point) {
+	e := engine.NewEngine()
+	testlevel.Load(tb, &e, testlevel.Microban(tb, 95))
+	surface := staticSurface(e.Surface)
+	MarkDeadFields(&surface)
+	boxes := []engine.Point{}
+	for _, box := range e.Boxes() {
+		boxes = append(boxes, box.Pos)
+	}
+	boxes = pushBox(boxes, Push{engine.NewPoint(3, 2), 3})
+	boxes = pushBox(boxes, Push{engine.NewPoint(2, 3), 2})
+	engine.SortPoints(boxes)
+	return surface, boxes, engine.NewPoint(3, 1)
+}
+
+func TestBoardPushes(t *testing.T) {
+	surface, boxes, fig := benchmarkPosition(t)
+	expected := []Push{
+		{engine.NewPoint(4, 2), 0}, {engine.NewPoint(4, 2), 1}, {engine.NewPoint(4, 2), 2}, {engine.NewPoint(5, 3), 2},
+		{engine.NewPoint(2, 4), 0}, {engine.NewPoint(2, 4), 1}, {engine.NewPoint(2, 4), 3}, {engine.NewPoint(5, 4), 2},
+		{engine.NewPoint(3, 5), 3}, {engine.NewPoint(4, 5), 3},
+	}
+	pushes := boardPushes(engine.NewBoard(surface).WithBoxes(boxes), boxes, fig)
+	if len(pushes) != len(expected) {
+		t.Fatalf("expected %d pushes, got %v", len(expected), pushes)
+	}
+	for i := range pushes {
+		if pushes[i] != expected[i] {
+			t.Errorf("push %d differs: %v != %v", i, pushes[i], expected[i])
+		}
+	}
+	// the surface based reference finds the same pushes
+	if pushes := surfacePushes(surface, boxes, fig); len(pushes) != len(expected) {
+		t.Errorf("expected %d surface pushes, got %v", len(expected), pushes)
+	} else {
+		for i := range pushes {
+			if pushes[i] != expected[i] {
+				t.Errorf("surface push %d differs: %v != %v", i, pushes[i], expected[i])
+			}
+		}
+	}
+	if fig := normalisedFigPos(engine.NewBoard(surface), boxes, engine.NewPoint(6, 6)); fig != engine.NewPoint(4, 1) {
+		t.Errorf("unexpected normalised figure position %v", fig)
+	}
+}
+
+func TestFrozen(t *testing.T) {
+	surface, boxes, _ := benchmarkPosition(t)
+	board := engine.NewBoard(surface)
+	// a box in a corner is frozen, unless it is on a point
+	if !frozen(board.WithBoxes([]engine.Point{engine.NewPoint(1, 1)}), engine.NewPoint(1, 1)) {
+		t.Error("box in the corner not frozen")
+	}
+	if frozen(board.WithBoxes(boxes), engine.NewPoint(4, 2)) {
+		t.Error("box in the middle frozen")
+	}
+	// two boxes next to each other at the wall
+	pair := []engine.Point{engine.NewPoint(3, 6), engine.NewPoint(4, 6)}
+	if !frozen(board.WithBoxes(pair), pair[0]) {
+		t.Error("boxes at the wall not frozen")
+	}
+}
+
+// pushes found on the surface with a breadth first search over single fields, the way the solvers did it
+// before the bitboard. It is kept as reference for TestBoardPushes and BenchmarkSurfacePushes.
+func surfacePushes(surface engine.Surface, boxes []engine.Point, fig engine.Point) (pushes []Push) {
+	occupied := make([][]bool, len(surface))
+	reachable := make([][]bool, len(surface))
+	for y := range surface {
+		occupied[y] = make([]bool, len(surface[y]))
+		reachable[y] = make([]bool, len(surface[y]))
+	}
+	for _, box := range boxes {
+		occupied[box.Y][box.X] = true
+	}
+	free := func(p engine.Point) bool {
+		return surface.In(p) && !surface[p.Y][p.X].Wall && !occupied[p.Y][p.X]
+	}
+	reachable[fig.Y][fig.X] = true
+	queue := []engine.Point{fig}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		for dir := engine.Direction(0); dir < 4; dir++ {
+			np := p.Add(dir.Point())
+			if free(np) && !reachable[np.Y][np.X] {
+				reachable[np.Y][np.X] = true
+				queue = append(queue, np)
+			}
+		}
+	}
+	for _, box := range boxes {
+		for dir := engine.Direction(0); dir < 4; dir++ {
+			from := box.Add(engine.Direction((dir + 2) % 4).Point())
+			to := box.Add(dir.Point())
+			if !surface.In(from) || !reachable[from.Y][from.X] || !free(to) || surface[to.Y][to.X].Dead {
+				continue
+			}
+			pushes = append(pushes, Push{box, dir})
+		}
+	}
+	return
+}
+
+func BenchmarkSurfacePushes(b *testing.B) {
+	surface, boxes, fig := benchmarkPosition(b)
+	for i := 0; i < b.N; i++ {
+		surfacePushes(surface, boxes, fig)
+	}
+}
+
+func BenchmarkBoardPushes(b *testing.B) {
+	surface, boxes, fig := benchmarkPosition(b)
+	board := engine.NewBoard(surface)
+	for i := 0; i < b.N; i++ {
+		boardPushes(board.WithBoxes(boxes), boxes, fig)
+	}
+}
+
+func BenchmarkSolvePushOptimal(b *testing.B) {
+	e := engine.NewEngine()
+	testlevel.Load(b, &e, testlevel.Microban(b, 95))
+	for i := 0; i < b.N; i++ {
+		SolvePushOptimal(e, 0)
+	}
+}
@@ -28,6 +28,7 @@ var (
 	starttime  syscall.Timeval
 	numWorkers int
 	running    bool
+	initial    engine.Engine // the level before any move, for writing solutions
 )
 
 func incSteps() {
@@ -48,6 +49,7 @@ func Run(e engine.Engine, single bool, outputFreq int32, printSurface bool, stra
 	cHistory = make(chan bool, 1)    // mutex on global history object
 
 	// preprocessing
+	initial = e.Clone()
 	MarkDeadFields(&e.Surface)
 	e.Print()
 
@@ -63,13 +65,14 @@ func Run(e engine.Engine, single bool, outputFreq int32, printSurface bool, stra
 
 	// create history store and save initial constellation
 	history = newHistoryTree(-1, -1)
-	newHist := e.GetBoxesAndX()
+	newHist := e.CanonicalBoxesAndX()
 	addHistory(&history, newHist)
 
 	// prepare for starting workers
 	wg.Add(1)
 	cDone <- 1
-	go runWorker(e, path, threads, single, outputFreq, printSurface, straightAhead)
+	// the first worker gets a clone, like all other workers, so its listener is not added to the engine of the caller
+	go runWorker(e.Clone(), path, threads, single, outputFreq, printSurface, straightAhead)
 
 	// wait for all workers to finish
 	wg.Wait()
@@ -87,6 +90,8 @@ func runWorker(e engine.Engine, basePath Path, threads int, single bool, outputF
 	numWorkers++
 	log.I(gorNo, "runWorker %d created, %d running", gorNo, runtime.NumGoroutine())
 	e.Id = gorNo
+	solved := &wonListener{}
+	e.AddListener(solved)
 	//	path := Path{basePath[len(basePath)-1].Clone()}
 	path := basePath[len(basePath)-1:]
 
@@ -128,13 +133,14 @@ func runWorker(e engine.Engine, basePath Path, threads int, single bool, outputF
 		}
 		// ### 4b. Try moving
 		log.D(e.Id, "Try moving in dir=%d", path.CurrentDir())
-		moved, boxMoved := e.Move(path.CurrentDir())
+		solved.won = false
+		moved, _ := e.Move(path.CurrentDir())
 		if !moved {
 			log.D(e.Id, "Could not move.")
 			continue
 		}
 		// ### 5. If moved, first check if not in a loop
-		newHist := e.GetBoxesAndX()
+		newHist := e.CanonicalBoxesAndX()
 		if everBeenHere(&history, newHist) {
 			log.D(e.Id, "I'v been here already. Backtrack: %d", newHist)
 			e.UndoStep()
@@ -158,12 +164,13 @@ func runWorker(e engine.Engine, basePath Path, threads int, single bool, outputF
 			log.I(gorNo, "Steps: %9d; %4dm %2ds %6dµs", steps, min, sec, µsec)
 		}
 		// ### 8. Do we already won? :)
-		if boxMoved != 0 && e.Won() {
+		if solved.won {
 			incSolutions()
 			min, sec, µsec := getTimePassed(starttime)
 			stepsCpy := steps
 			log.Lock <- 1
-			log.A("%d. solution found after %d steps, %4dm %2ds %6dµs.\nPath: %d%d\n", solutions, stepsCpy, min, sec, µsec, basePath.Directions(), path.Directions())
+			lurd, _ := initial.FormatLURD(solutionDirections(basePath, path))
+			log.A("%d. solution found after %d steps, %4dm %2ds %6dµs.\nPath: %s\n", solutions, stepsCpy, min, sec, µsec, lurd)
 			<-log.Lock
 			e.Print()
 			solSteps = append(solSteps, stepsCpy)
@@ -192,6 +199,22 @@ func runWorker(e engine.Engine, basePath Path, threads int, single bool, outputF
 	log.I(gorNo, "runWorker %d finished", gorNo)
 }
 
+// remembers, if the level was won by the last move
+type wonListener struct {
+	engine.NopListener
+	won bool
+}
+
+func (l *wonListener) Won(e *engine.Engine) {
+	l.won = true
+}
+
+// directions of the base path and the path, without the last node, that was not tried yet
+func solutionDirections(basePath Path, path Path) []engine.Direction {
+	dirs := append(basePath.Directions(), path.Directions()...)
+	return dirs[:len(dirs)-1]
+}
+
 func everBeenHere(h *HistoryTree, boxes []engine.Point) bool {
 	for i := 0; i < len(boxes); i++ {
 		box := boxes[i]
@@ -225,9 +248,9 @@ func addHistory(h *HistoryTree, boxes []engine.Point) {
 	}
 }
 
-func insertNewHist(h *HistoryTree, boxList []engine.Point, counter int8) (newHis HistoryTree) {
+func insertNewHist(h *HistoryTree, boxList []engine.Point, counter int) (newHis HistoryTree) {
 	counter++
-	if int8(len(boxList)) == counter {
+	if len(boxList) == counter {
 		return
 	}
 	cHistory <- true
@@ -240,8 +263,8 @@ func insertNewHist(h *HistoryTree, boxList []engine.Point, counter int8) (newHis
 	return
 }
 
-func newHistoryTree(x int8, y int8) HistoryTree {
-	return HistoryTree{engine.NewPoint8(x, y), nil}
+func newHistoryTree(x int, y int) HistoryTree {
+	return HistoryTree{engine.NewPoint(x, y), nil}
 }
 
 func searchSons(h *HistoryTree, box engine.Point) int {
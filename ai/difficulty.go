@@ -0,0 +1,60 @@
+package ai
+
+import (
+	"math"
+
+	"github.com/g3force/Go_Sokoban/engine"
+)
+
+// weights of the single metrics in the difficulty score
+const (
+	STATES_WEIGHT      = 10.0 // per decimal power of explored states
+	PUSHES_WEIGHT      = 1.0
+	MOVES_WEIGHT       = 0.1
+	BOX_CHANGES_WEIGHT = 2.0
+	DEAD_RATIO_WEIGHT  = 20.0 // for a level, where all fields are dead
+)
+
+// solver metrics of a level and the score, that combines them
+type Difficulty struct {
+	States     int     // states explored by the push optimal solver
+	Pushes     int     // pushes of the push optimal solution
+	Moves      int     // moves of the push optimal solution
+	BoxChanges int     // how often the solution switches to another box
+	DeadRatio  float64 // dead fields per field, that is no wall
+	Score      float64 // weighted sum of all metrics, higher is harder
+}
+
+// estimate the difficulty of the level by solving it push optimal.
+// Returns false, if no solution was found within maxStates states.
+func EstimateDifficulty(e engine.Engine, maxStates int) (d Difficulty, solved bool) {
+	sol, solved := SolvePushOptimal(e, maxStates)
+	d.States = sol.States
+	d.Pushes = len(sol.Pushes)
+	d.Moves = len(sol.Moves)
+	d.BoxChanges = boxChanges(sol.Pushes)
+
+	surface := staticSurface(e.Surface)
+	MarkDeadFields(&surface)
+	fields, dead := surface.AmountOfFields()
+	if fields > 0 {
+		d.DeadRatio = float64(dead) / float64(fields)
+	}
+
+	d.Score = STATES_WEIGHT*math.Log10(float64(1+d.States)) +
+		PUSHES_WEIGHT*float64(d.Pushes) +
+		MOVES_WEIGHT*float64(d.Moves) +
+		BOX_CHANGES_WEIGHT*float64(d.BoxChanges) +
+		DEAD_RATIO_WEIGHT*d.DeadRatio
+	return
+}
+
+// count, how often a push moves another box than the push before
+func boxChanges(pushes []Push) (changes int) {
+	for i := 1; i < len(pushes); i++ {
+		if pushes[i].Box != pushes[i-1].Box.Add(pushes[i-1].Dir.Point()) {
+			changes++
+		}
+	}
+	return
+}
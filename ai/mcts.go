@@ -0,0 +1,234 @@
+package ai
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/g3force/Go_Sokoban/engine"
+)
+
+// parameters of the monte carlo tree search
+type MCTSConfig struct {
+	Iterations   int     // number of playouts, that may be done
+	RolloutDepth int     // maximum number of random pushes within a single playout
+	Exploration  float64 // weight of the exploration term in UCT
+	Seed         int64   // seed of the random generator
+}
+
+// node of the search tree, a state after a push
+type mctsNode struct {
+	parent   *mctsNode
+	push     Push           // push, that leads from the parent to this node
+	boxes    []engine.Point // positions of all boxes
+	fig      engine.Point   // position of the figure
+	children []*mctsNode
+	untried  []Push // pushes, that have no child yet
+	visits   int
+	reward   float64 // sum of all rewards of the playouts through this node
+}
+
+func NewMCTSConfig() MCTSConfig {
+	return MCTSConfig{
+		Iterations:   100000,
+		RolloutDepth: 50,
+		Exploration:  math.Sqrt2,
+		Seed:         1,
+	}
+}
+
+// search a solution by a monte carlo tree search with random push playouts.
+// The reward of a state depends on the distances of the boxes to the nearest points.
+// After a solution was found, the search goes on within the configured iterations for a solution with less pushes.
+// Returns the best partial solution, if no full solution was found.
+func SolveMCTS(e engine.Engine, c MCTSConfig) (sol Solution, won bool) {
+	rnd := rand.New(rand.NewSource(c.Seed))
+	surface := staticSurface(e.Surface)
+	MarkDeadFields(&surface)
+	board := engine.NewBoard(surface)
+
+	boxes := []engine.Point{}
+	for _, box := range e.Boxes() {
+		boxes = append(boxes, box.Pos)
+	}
+	root := newMCTSNode(board, nil, Push{}, boxes, e.FigPos())
+
+	bestReward := goalDistanceReward(surface, boxes)
+	bestPushes := []Push{}
+	won = allPointsCovered(surface, boxes)
+	// every box has to be pushed at least to its nearest point, no solution can have less pushes
+	minPushes := goalDistance(surface, boxes)
+	// remember the given state, if it is better than all states before
+	consider := func(pushes []Push, boxes []engine.Point) {
+		r := goalDistanceReward(surface, boxes)
+		if allPointsCovered(surface, boxes) {
+			pushes = removeCycles(board, root.boxes, root.fig, pushes)
+		}
+		if r > bestReward || (r == bestReward && len(pushes) < len(bestPushes)) {
+			bestReward = r
+			bestPushes = append([]Push{}, pushes...)
+			won = allPointsCovered(surface, boxes)
+		}
+	}
+
+	for i := 0; i < c.Iterations && !(won && len(bestPushes) <= minPushes); i++ {
+		sol.States++
+		// selection
+		node := root
+		for len(node.untried) == 0 && len(node.children) > 0 {
+			node = node.bestChild(c.Exploration)
+		}
+		// expansion
+		if len(node.untried) > 0 {
+			k := rnd.Intn(len(node.untried))
+			push := node.untried[k]
+			node.untried = append(node.untried[:k], node.untried[k+1:]...)
+			child := newMCTSNode(board, node, push, pushBox(node.boxes, push), push.Box)
+			node.children = append(node.children, child)
+			node = child
+		}
+		// rollout
+		pushes := node.pushes()
+		boxes, fig := node.boxes, node.fig
+		consider(pushes, boxes)
+		for depth := 0; depth < c.RolloutDepth && !allPointsCovered(surface, boxes); depth++ {
+			// a longer playout can not lead to a shorter solution
+			if won && len(pushes)+1 >= len(bestPushes) {
+				break
+			}
+			candidates := safePushes(board, boxes, fig)
+			if len(candidates) == 0 {
+				break
+			}
+			push := candidates[rnd.Intn(len(candidates))]
+			boxes, fig = pushBox(boxes, push), push.Box
+			pushes = append(pushes, push)
+			consider(pushes, boxes)
+		}
+		// backpropagation
+		r := goalDistanceReward(surface, boxes)
+		for n := node; n != nil; n = n.parent {
+			n.visits++
+			n.reward += r
+		}
+	}
+
+	sol.Pushes = removeCycles(board, root.boxes, root.fig, bestPushes)
+	sol.Moves = movesFor(board, root.boxes, root.fig, sol.Pushes)
+	return
+}
+
+// drop the pushes between two visits of the same state, like a box, that is pushed forth and back again.
+// States are compared with the normalised figure position, so the remaining pushes are still possible.
+func removeCycles(board *engine.Board, boxes []engine.Point, fig engine.Point, pushes []Push) []Push {
+	states := []engine.State{engine.NewState(normalisedFigPos(board, boxes, fig), boxes)}
+	last := map[engine.State]int{states[0]: 0}
+	for i, push := range pushes {
+		boxes = pushBox(boxes, push)
+		states = append(states, engine.NewState(normalisedFigPos(board, boxes, push.Box), boxes))
+		last[states[i+1]] = i + 1
+	}
+	// continue after the last visit of every state
+	remaining := []Push{}
+	for i := last[states[0]]; i < len(pushes); i = last[states[i+1]] {
+		remaining = append(remaining, pushes[i])
+	}
+	return remaining
+}
+
+func newMCTSNode(board *engine.Board, parent *mctsNode, push Push, boxes []engine.Point, fig engine.Point) *mctsNode {
+	node := &mctsNode{parent: parent, push: push, boxes: boxes, fig: fig}
+	if !board.WithBoxes(boxes).Won() {
+		node.untried = safePushes(board, boxes, fig)
+	}
+	return node
+}
+
+// child with the highest upper confidence bound
+func (node *mctsNode) bestChild(exploration float64) (best *mctsNode) {
+	bestValue := math.Inf(-1)
+	for _, child := range node.children {
+		value := child.reward/float64(child.visits) +
+			exploration*math.Sqrt(math.Log(float64(node.visits))/float64(child.visits))
+		if value > bestValue {
+			best, bestValue = child, value
+		}
+	}
+	return
+}
+
+// pushes from the root to this node
+func (node *mctsNode) pushes() []Push {
+	pushes := []Push{}
+	for n := node; n.parent != nil; n = n.parent {
+		pushes = append([]Push{n.push}, pushes...)
+	}
+	return pushes
+}
+
+// all possible pushes, that do not lead into a deadlock
+func safePushes(board *engine.Board, boxes []engine.Point, fig engine.Point) (pushes []Push) {
+	for _, push := range boardPushes(board.WithBoxes(boxes), boxes, fig) {
+		if !frozen(board.WithBoxes(pushBox(boxes, push)), push.Box.Add(push.Dir.Point())) {
+			pushes = append(pushes, push)
+		}
+	}
+	return
+}
+
+// check, if the box at the given position is part of a 2x2 block of walls and boxes,
+// where at least one box is not on a point. Such boxes can never be moved again.
+func frozen(board *engine.Board, box engine.Point) bool {
+	for dy := -1; dy <= 0; dy++ {
+		for dx := -1; dx <= 0; dx++ {
+			blocked, misplaced := true, false
+			for i := 0; i < 4 && blocked; i++ {
+				p := board.Index(engine.NewPoint(box.X+dx+i%2, box.Y+dy+i/2))
+				switch {
+				case board.Boxes.Has(p):
+					misplaced = misplaced || !board.Points.Has(p)
+				case !board.Free(p):
+				default:
+					blocked = false
+				}
+			}
+			if blocked && misplaced {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// reward between 0 and 1, the nearer the boxes are to the points, the higher is the reward
+func goalDistanceReward(surface engine.Surface, boxes []engine.Point) float64 {
+	return 1 / float64(1+goalDistance(surface, boxes))
+}
+
+// sum of the distances of all boxes to their nearest points
+func goalDistance(surface engine.Surface, boxes []engine.Point) (distance int) {
+	for _, box := range boxes {
+		nearest := -1
+		for y := 0; y < len(surface); y++ {
+			for x := 0; x < len(surface[y]); x++ {
+				if !surface[y][x].Point {
+					continue
+				}
+				d := abs(x-box.X) + abs(y-box.Y)
+				if nearest == -1 || d < nearest {
+					nearest = d
+				}
+			}
+		}
+		if nearest > 0 {
+			distance += nearest
+		}
+	}
+	return
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
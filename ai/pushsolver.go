@@ -0,0 +1,209 @@
+package ai
+
+import (
+	"github.com/g3force/Go_Sokoban/engine"
+)
+
+// a single push of a box into a direction
+type Push struct {
+	Box engine.Point     // position of the box before the push
+	Dir engine.Direction // direction, the box is pushed to
+}
+
+// result of a push optimal search
+type Solution struct {
+	Pushes []Push             // push optimal sequence of pushes
+	Moves  []engine.Direction // complete path of the figure, including the walks between pushes
+	States int                // number of states explored
+}
+
+// node of the breadth first search
+type pushState struct {
+	boxes  []engine.Point // positions of all boxes, sorted
+	fig    engine.Point   // normalised figure position (first reachable field)
+	parent int            // index of the previous state, -1 for the initial state
+	push   Push           // push, that leads from the parent to this state
+}
+
+// search a solution with the minimal number of pushes by a breadth first search over all box constellations.
+// At most maxStates states are explored, 0 means no limit.
+func SolvePushOptimal(e engine.Engine, maxStates int) (sol Solution, found bool) {
+	surface := staticSurface(e.Surface)
+	MarkDeadFields(&surface)
+
+	boxes := []engine.Point{}
+	for _, box := range e.Boxes() {
+		boxes = append(boxes, box.Pos)
+	}
+	engine.SortPoints(boxes)
+
+	board := engine.NewBoard(surface)
+	start := pushState{boxes, normalisedFigPos(board, boxes, e.FigPos()), -1, Push{}}
+	states := []pushState{start}
+	symmetries := e.Symmetries()
+	visited := map[engine.State]bool{stateKey(board, symmetries, start.boxes, start.fig): true}
+
+	for i := 0; i < len(states); i++ {
+		if maxStates > 0 && i >= maxStates {
+			break
+		}
+		sol.States++
+		state := states[i]
+		if allPointsCovered(surface, state.boxes) {
+			sol.Pushes = pushesTo(states, i)
+			sol.Moves = movesFor(board, boxes, e.FigPos(), sol.Pushes)
+			found = true
+			return
+		}
+		for _, push := range boardPushes(board.WithBoxes(state.boxes), state.boxes, state.fig) {
+			newBoxes := pushBox(state.boxes, push)
+			engine.SortPoints(newBoxes)
+			newFig := normalisedFigPos(board, newBoxes, push.Box)
+			key := stateKey(board, symmetries, newBoxes, newFig)
+			if visited[key] {
+				continue
+			}
+			visited[key] = true
+			states = append(states, pushState{newBoxes, newFig, i, push})
+		}
+	}
+	return
+}
+
+// all pushes, the figure can do from its position on a board with the given boxes without moving a box onto a dead field.
+// The reachable fields are found by a word parallel flood fill.
+func boardPushes(board *engine.Board, boxes []engine.Point, fig engine.Point) (pushes []Push) {
+	reachable := board.Reachable(fig)
+	for _, box := range boxes {
+		i := board.Index(box)
+		for dir := engine.Direction(0); dir < 4; dir++ {
+			offset := board.Offset(dir)
+			if !reachable.Has(i-offset) || !board.Free(i+offset) || board.Dead.Has(i+offset) {
+				continue
+			}
+			pushes = append(pushes, Push{box, dir})
+		}
+	}
+	return
+}
+
+// copy of the boxes after the given push
+func pushBox(boxes []engine.Point, push Push) []engine.Point {
+	newBoxes := make([]engine.Point, len(boxes))
+	copy(newBoxes, boxes)
+	for b := range newBoxes {
+		if newBoxes[b] == push.Box {
+			newBoxes[b] = push.Box.Add(push.Dir.Point())
+		}
+	}
+	return newBoxes
+}
+
+// copy the surface without any boxes
+func staticSurface(surface engine.Surface) (ns engine.Surface) {
+	ns = surface.Clone()
+	for y := 0; y < len(ns); y++ {
+		for x := 0; x < len(ns[y]); x++ {
+			ns[y][x].Box = engine.EMPTY
+		}
+	}
+	return
+}
+
+// unique key of a box constellation and a normalised figure position.
+// Constellations, that are symmetric to each other, get the same key.
+func stateKey(board *engine.Board, symmetries []engine.Symmetry, boxes []engine.Point, fig engine.Point) engine.State {
+	width, height := board.Width, board.Height
+	var state []engine.Point
+	for _, s := range symmetries {
+		candidate := []engine.Point{fig}
+		for _, box := range boxes {
+			candidate = append(candidate, s.Transform(box, width, height))
+		}
+		engine.SortPoints(candidate[1:])
+		if s != engine.IDENTITY {
+			candidate[0] = normalisedFigPos(board, candidate[1:], s.Transform(fig, width, height))
+		}
+		if state == nil || engine.LessPoints(candidate, state) {
+			state = candidate
+		}
+	}
+	return engine.NewState(state[0], state[1:])
+}
+
+// check, if there is a box on every point
+func allPointsCovered(surface engine.Surface, boxes []engine.Point) bool {
+	covered := 0
+	for _, box := range boxes {
+		if surface[box.Y][box.X].Point {
+			covered++
+		}
+	}
+	for y := 0; y < len(surface); y++ {
+		for x := 0; x < len(surface[y]); x++ {
+			if surface[y][x].Point {
+				covered--
+			}
+		}
+	}
+	return covered >= 0
+}
+
+// the first field in reading order, the figure can reach
+func normalisedFigPos(board *engine.Board, boxes []engine.Point, fig engine.Point) engine.Point {
+	if i := board.WithBoxes(boxes).Reachable(fig).Next(0); i >= 0 {
+		return board.Point(i)
+	}
+	return fig
+}
+
+// collect the pushes from the initial state to the given state
+func pushesTo(states []pushState, i int) []Push {
+	pushes := []Push{}
+	for ; states[i].parent != -1; i = states[i].parent {
+		pushes = append(pushes, states[i].push)
+	}
+	for l, r := 0, len(pushes)-1; l < r; l, r = l+1, r-1 {
+		pushes[l], pushes[r] = pushes[r], pushes[l]
+	}
+	return pushes
+}
+
+// expand the pushes to a complete path of the figure, walking the shortest way between two pushes
+func movesFor(board *engine.Board, boxes []engine.Point, fig engine.Point, pushes []Push) []engine.Direction {
+	moves := []engine.Direction{}
+	for _, push := range pushes {
+		moves = append(moves, walkPath(board.WithBoxes(boxes), fig, push.Box.Add(engine.Direction((push.Dir+2)%4).Point()))...)
+		moves = append(moves, push.Dir)
+		boxes = pushBox(boxes, push)
+		fig = push.Box
+	}
+	return moves
+}
+
+// shortest path of the figure between two fields without pushing a box
+func walkPath(board *engine.Board, from engine.Point, to engine.Point) []engine.Direction {
+	came := map[engine.Point]engine.Direction{from: engine.NO_DIRECTION}
+	queue := []engine.Point{from}
+	for len(queue) > 0 && queue[0] != to {
+		p := queue[0]
+		queue = queue[1:]
+		for dir := engine.Direction(0); dir < 4; dir++ {
+			np := p.Add(dir.Point())
+			if _, seen := came[np]; !seen && board.Free(board.Index(np)) {
+				came[np] = dir
+				queue = append(queue, np)
+			}
+		}
+	}
+	path := []engine.Direction{}
+	for p := to; p != from; {
+		dir, ok := came[p]
+		if !ok {
+			return nil
+		}
+		path = append([]engine.Direction{dir}, path...)
+		p = p.Add(engine.Direction((dir + 2) % 4).Point())
+	}
+	return path
+}
@@ -0,0 +1,211 @@
+package engine
+
+import (
+	"math/bits"
+)
+
+// set of fields, one bit per field. Bit i is stored in word i/64.
+type Bits []uint64
+
+func newBits(n int) Bits {
+	return make(Bits, (n+63)/64)
+}
+
+// check, if bit i is set. Bits outside of the set are never set.
+func (b Bits) Has(i int) bool {
+	if i < 0 || i >= len(b)*64 {
+		return false
+	}
+	return b[i/64]&(1<<uint(i%64)) != 0
+}
+
+func (b Bits) Set(i int) {
+	b[i/64] |= 1 << uint(i%64)
+}
+
+func (b Bits) Clear(i int) {
+	b[i/64] &^= 1 << uint(i%64)
+}
+
+// number of set bits
+func (b Bits) Count() (count int) {
+	for _, w := range b {
+		count += bits.OnesCount64(w)
+	}
+	return
+}
+
+// index of the first set bit at or after i, -1 if there is none
+func (b Bits) Next(i int) int {
+	if i < 0 {
+		i = 0
+	}
+	for w := i / 64; w < len(b); w++ {
+		word := b[w]
+		if w == i/64 {
+			word &= ^uint64(0) << uint(i%64)
+		}
+		if word != 0 {
+			return w*64 + bits.TrailingZeros64(word)
+		}
+	}
+	return -1
+}
+
+// store b shifted by n bits towards higher indices (n > 0) or lower indices (n < 0) in dst
+func (b Bits) shift(dst Bits, n int) {
+	if n >= 0 {
+		w, r := n/64, uint(n%64)
+		for i := len(b) - 1; i >= 0; i-- {
+			var v uint64
+			if i-w >= 0 {
+				v = b[i-w] << r
+				if r > 0 && i-w-1 >= 0 {
+					v |= b[i-w-1] >> (64 - r)
+				}
+			}
+			dst[i] = v
+		}
+		return
+	}
+	w, r := -n/64, uint(-n%64)
+	for i := 0; i < len(b); i++ {
+		var v uint64
+		if i+w < len(b) {
+			v = b[i+w] >> r
+			if r > 0 && i+w+1 < len(b) {
+				v |= b[i+w+1] << (64 - r)
+			}
+		}
+		dst[i] = v
+	}
+}
+
+// compact representation of a surface, one bit set per field for walls, points, dead fields and boxes.
+// Every row is followed by a wall, so a shift by one field never wraps into the next row.
+type Board struct {
+	Width  int
+	Height int
+	stride int  // number of bits per row
+	Walls  Bits // walls, void fields and everything outside of the surface
+	Points Bits
+	Dead   Bits
+	Boxes  Bits
+}
+
+// create a board with the walls, points, dead fields and boxes of the surface
+func NewBoard(surface Surface) *Board {
+	width, height := surface.Size()
+	b := &Board{Width: width, Height: height, stride: width + 1}
+	n := b.stride * height
+	b.Walls, b.Points, b.Dead, b.Boxes = newBits(n), newBits(n), newBits(n), newBits(n)
+	for i := range b.Walls {
+		b.Walls[i] = ^uint64(0)
+	}
+	for y := 0; y < len(surface); y++ {
+		for x := 0; x < len(surface[y]); x++ {
+			i := b.Index(NewPoint(x, y))
+			field := surface[y][x]
+			if !field.Wall {
+				b.Walls.Clear(i)
+			}
+			if field.Point {
+				b.Points.Set(i)
+			}
+			if field.Dead {
+				b.Dead.Set(i)
+			}
+			if field.Box != EMPTY {
+				b.Boxes.Set(i)
+			}
+		}
+	}
+	return b
+}
+
+// board of the current surface of the engine
+func (e *Engine) Board() *Board {
+	return NewBoard(e.Surface)
+}
+
+// copy of the board, that shares the static fields, but with other boxes
+func (b *Board) WithBoxes(boxes []Point) *Board {
+	nb := *b
+	nb.Boxes = newBits(b.stride * b.Height)
+	for _, box := range boxes {
+		nb.Boxes.Set(b.Index(box))
+	}
+	return &nb
+}
+
+// index of the bit for the given field
+func (b *Board) Index(p Point) int {
+	return p.Y*b.stride + p.X
+}
+
+// field of the given index
+func (b *Board) Point(i int) Point {
+	return NewPoint(i%b.stride, i/b.stride)
+}
+
+// difference of the indices of two neighbouring fields in the given direction
+func (b *Board) Offset(dir Direction) int {
+	switch dir {
+	case 0:
+		return 1
+	case 1:
+		return b.stride
+	case 2:
+		return -1
+	case 3:
+		return -b.stride
+	}
+	return 0
+}
+
+// check, if the figure or a box may enter the field with the given index
+func (b *Board) Free(i int) bool {
+	return i >= 0 && i < b.stride*b.Height && !b.Walls.Has(i) && !b.Boxes.Has(i)
+}
+
+// check, if all boxes are on points
+func (b *Board) Won() bool {
+	for i, w := range b.Boxes {
+		if w&^b.Points[i] != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// all fields, the figure can reach from the given position without pushing a box.
+// The flood fill grows into all four directions at once, 64 fields per operation.
+func (b *Board) Reachable(fig Point) Bits {
+	free := make(Bits, len(b.Walls))
+	for i := range free {
+		free[i] = ^(b.Walls[i] | b.Boxes[i])
+	}
+	reach, next, shifted := make(Bits, len(free)), make(Bits, len(free)), make(Bits, len(free))
+	reach.Set(b.Index(fig))
+	offsets := []int{1, -1, b.stride, -b.stride}
+	for {
+		copy(next, reach)
+		for _, n := range offsets {
+			reach.shift(shifted, n)
+			for i := range next {
+				next[i] |= shifted[i]
+			}
+		}
+		changed := false
+		for i := range next {
+			next[i] &= free[i]
+			if next[i] != reach[i] {
+				changed = true
+			}
+		}
+		if !changed {
+			return reach
+		}
+		reach, next = next, reach
+	}
+}
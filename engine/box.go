@@ -4,10 +4,10 @@ import ()
 
 type Box struct {
 	Pos   Point
-	Order int8
+	Order int
 }
 
-func NewBox(pos Point, order int8) Box {
+func NewBox(pos Point, order int) Box {
 	return Box{pos, order}
 }
 
@@ -19,6 +19,6 @@ func (b *Box) SetPos(p Point) {
 	b.Pos = p
 }
 
-func (b *Box) SetOrder(order int8) {
+func (b *Box) SetOrder(order int) {
 	b.Order = order
 }
@@ -24,6 +24,16 @@ func (dir Direction) Point() Point {
 	return p
 }
 
-func (dir Direction) Int() int8 {
-	return (int8) (dir)
+func (dir Direction) Int() int {
+	return (int) (dir)
+}
+
+// convert a Point with a distance of one to a direction, NO_DIRECTION for all other points
+func PointDirection(p Point) Direction {
+	for dir := Direction(0); dir < 4; dir++ {
+		if dir.Point() == p {
+			return dir
+		}
+	}
+	return NO_DIRECTION
 }
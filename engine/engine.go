@@ -1,7 +1,10 @@
 package engine
 
 import (
+	"errors"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"github.com/g3force/Go_Sokoban/log"
 	"strings"
@@ -14,7 +17,7 @@ const EMPTY = 0
 type HistoryType struct {
 	OldPos   Point
 	NewPos   Point
-	BoxMoved int8
+	BoxMoved int
 }
 
 // single field within the surface
@@ -22,13 +25,14 @@ type Field struct {
 	Wall  bool
 	Point bool
 	Dead  bool
-	Box   int8
+	Box   int
+	Void  bool // outside of the level, always marked as wall too
 }
 
 type MovingResult struct {
 	moved    bool
 	boxMoved bool
-	box      int8
+	box      int
 }
 
 type Surface [][]Field
@@ -36,16 +40,26 @@ type Surface [][]Field
 type Engine struct {
 	Surface      Surface       // the current Surface
 	History      []HistoryType // history, indicating the past way
+	redo         []HistoryType // undone steps, the last one is redone first
+	moves        int           // number of moves within the history
+	pushes       int           // number of pushes within the history
+	boxChanges   int           // how often a push moved another box than the push before
+	pushedBoxes  []int         // ids of the pushed boxes, one per push
+	boxPushes    map[int]int   // number of pushes per box id
+	listeners    []Listener    // receive the events of this engine, not cloned
 	figPos       Point         // current position of figure
 	points       []Point       // Array of all points
-	boxes        map[int8]*Box // Array of all boxes
-	boxesOrdered map[int8]*Box
+	boxes        map[int]*Box // Array of all boxes
+	boxesOrdered map[int]*Box
+	symmetries   []Symmetry // symmetries of the surface, at least the identity
 	Id 				int
 }
 
 func NewEngine() (e Engine) {
-	e.boxes = map[int8]*Box{}
-	e.boxesOrdered = map[int8]*Box{}
+	e.boxes = map[int]*Box{}
+	e.boxesOrdered = map[int]*Box{}
+	e.symmetries = []Symmetry{IDENTITY}
+	e.boxPushes = map[int]int{}
 	return
 }
 
@@ -55,6 +69,7 @@ func (e *Engine) Clone() (ne Engine) {
 	ne.History = []HistoryType{} // empty
 	ne.figPos = Point{e.FigPos().X, e.FigPos().Y}
 	ne.points = e.points // won't change
+	ne.symmetries = e.symmetries
 	for k, v := range e.boxes {
 		box := v.Clone()
     	ne.boxes[k] = &box
@@ -67,7 +82,7 @@ func (e Engine) Points() []Point {
 	return e.points
 }
 
-func (e Engine) Boxes() map[int8]*Box {
+func (e Engine) Boxes() map[int]*Box {
 	return e.boxes
 }
 
@@ -75,7 +90,11 @@ func (e Engine) FigPos() Point {
 	return e.figPos
 }
 
-func (surface Surface) AmountOfFields() (fields int, dead int8) {
+func (e Engine) Symmetries() []Symmetry {
+	return e.symmetries
+}
+
+func (surface Surface) AmountOfFields() (fields int, dead int) {
 	for y := 0; y < len(surface); y++ {
 		for x := 0; x < len(surface[y]); x++ {
 			if !surface[y][x].Wall {
@@ -103,8 +122,17 @@ func (surface *Surface) Clone() (ns Surface) {
 
 /* try moving figure in specified direction.
  * Returns, if figure was moved and if figure moved a box.
+ * A successful move clears the steps, that could be redone.
  */
-func (e *Engine) Move(dir Direction) (success bool, boxMoved int8) {
+func (e *Engine) Move(dir Direction) (success bool, boxMoved int) {
+	success, boxMoved = e.move(dir)
+	if success {
+		e.redo = e.redo[:0]
+	}
+	return
+}
+
+func (e *Engine) move(dir Direction) (success bool, boxMoved int) {
 	success = false
 	boxMoved = EMPTY
 	cf := e.FigPos()          // current figureposition
@@ -144,17 +172,19 @@ func (e *Engine) Move(dir Direction) (success bool, boxMoved int8) {
 		hist.OldPos = cf
 		hist.BoxMoved = boxMoved
 		e.History = append(e.History, hist)
+		e.count(hist)
 		e.Surface[nf.Y][nf.X].Box = e.Surface[cf.Y][cf.X].Box
 		e.Surface[cf.Y][cf.X].Box = EMPTY
 		e.figPos = nf // refresh figureposition
 		success = true
+		e.notifyMove(hist)
 	default:
 		log.E(e.Id,"Unknown field")
 	}
 	return
 }
 
-func (e *Engine) reOrderBoxes(curBoxId int8, dir Direction) {
+func (e *Engine) reOrderBoxes(curBoxId int, dir Direction) {
 	switch dir {
 	case 1: // down
 	case -1: // up
@@ -166,7 +196,7 @@ func (e *Engine) reOrderBoxes(curBoxId int8, dir Direction) {
 	}
 	curBox := e.boxes[curBoxId]
 	curOrder := curBox.Order
-	if curOrder+dir.Int() <= 0 || curOrder+dir.Int() > int8(len(e.boxes)) {
+	if curOrder+dir.Int() <= 0 || curOrder+dir.Int() > len(e.boxes) {
 		return
 	}
 	nextBox := e.boxesOrdered[curOrder+dir.Int()]
@@ -197,7 +227,7 @@ func (e *Engine) reOrderBoxes(curBoxId int8, dir Direction) {
 	e.reOrderBoxes(curBoxId, dir)
 }
 
-// undo the last step (move figure and box to their old positions)
+// undo the last step (move figure and box to their old positions). The step can be redone afterwards.
 func (e *Engine) UndoStep() {
 	if len(e.History) > 0 {
 		history := e.History[len(e.History)-1] // get last history
@@ -214,87 +244,320 @@ func (e *Engine) UndoStep() {
 			e.boxes[e.Surface[history.NewPos.Y][history.NewPos.X].Box].SetPos(history.NewPos)
 			// if movement was up or down
 			if history.NewPos.X-history.OldPos.X == 0 {
-				e.reOrderBoxes(history.BoxMoved, (Direction)((int8)(history.OldPos.Y-history.NewPos.Y)))
+				e.reOrderBoxes(history.BoxMoved, (Direction)(history.OldPos.Y-history.NewPos.Y))
 			}
 		}
 		e.History = e.History[:len(e.History)-1] // remove from history
+		e.uncount(history)
+		e.redo = append(e.redo, history)
+		e.notifyUndo(history)
+	}
+}
+
+// update the counters for a new step in the history
+func (e *Engine) count(hist HistoryType) {
+	e.moves++
+	if hist.BoxMoved == EMPTY {
+		return
+	}
+	e.pushes++
+	if e.boxPushes == nil {
+		e.boxPushes = map[int]int{}
+	}
+	e.boxPushes[hist.BoxMoved]++
+	if len(e.pushedBoxes) > 0 && e.pushedBoxes[len(e.pushedBoxes)-1] != hist.BoxMoved {
+		e.boxChanges++
+	}
+	e.pushedBoxes = append(e.pushedBoxes, hist.BoxMoved)
+}
+
+// update the counters for a step, that was removed from the history
+func (e *Engine) uncount(hist HistoryType) {
+	e.moves--
+	if hist.BoxMoved == EMPTY {
+		return
+	}
+	e.pushes--
+	e.boxPushes[hist.BoxMoved]--
+	e.pushedBoxes = e.pushedBoxes[:len(e.pushedBoxes)-1]
+	if len(e.pushedBoxes) > 0 && e.pushedBoxes[len(e.pushedBoxes)-1] != hist.BoxMoved {
+		e.boxChanges--
+	}
+}
+
+// number of moves of the figure within the history
+func (e *Engine) Moves() int {
+	return e.moves
+}
+
+// number of moves within the history, that pushed a box
+func (e *Engine) Pushes() int {
+	return e.pushes
+}
+
+// how often a push moved another box than the push before
+func (e *Engine) BoxChanges() int {
+	return e.boxChanges
+}
+
+// number of pushes of the box with the given id within the history
+func (e *Engine) BoxPushes(id int) int {
+	return e.boxPushes[id]
+}
+
+// undo up to n steps. Returns the number of steps, that were undone.
+func (e *Engine) Undo(n int) (undone int) {
+	for ; undone < n && len(e.History) > 0; undone++ {
+		e.UndoStep()
+	}
+	return
+}
+
+// redo up to n steps, that were undone before. Returns the number of steps, that were redone.
+// Stops at a step, that is not possible anymore, e.g. because of new dead fields. That step stays redoable.
+func (e *Engine) Redo(n int) (redone int) {
+	for ; redone < n && len(e.redo) > 0; redone++ {
+		step := e.redo[len(e.redo)-1]
+		if moved, _ := e.move(PointDirection(NewPoint(step.NewPos.X-step.OldPos.X, step.NewPos.Y-step.OldPos.Y))); !moved {
+			break
+		}
+		e.redo = e.redo[:len(e.redo)-1]
 	}
+	return
+}
+
+// number of steps, that can be redone
+func (e *Engine) RedoSteps() int {
+	return len(e.redo)
+}
+
+// undo all steps, so the level is at its initial position again. All steps can be redone afterwards.
+func (e *Engine) Restart() {
+	e.Undo(len(e.History))
+}
+
+// error in the content of a level
+type ParseError struct {
+	Line   int   // line within the level, starting at 1
+	Column int   // column within the line, starting at 1
+	Char   uint8 // the offending character
+}
+
+func (err *ParseError) Error() string {
+	return fmt.Sprintf("line %d, column %d: unknown character %q in level", err.Line, err.Column, err.Char)
 }
 
+// the level contains no row
+var ErrNoLevel = errors.New("no level found")
+
 // load level from specified file (relative to binary file)
-func (e *Engine) LoadLevel(filename string) {
-	raw, err := readLevelAsString(filename)
+func (e *Engine) LoadLevel(filename string) error {
+	f, err := os.Open(filename)
 	if err != nil {
-		panic(err)
+		return err
 	}
-	// remove the "\r" from stupid windows files...
-	raw = strings.Replace(raw, "\r", "", -1)
-	// get single lines in an array
-	lines := strings.Split(raw, "\n")
+	defer f.Close()
+	return e.ReadLevel(f)
+}
 
-	e.Surface = Surface{{}}
-	var field Field
-	y := 0
-	boxId := int8(0)
-	maxlen := 0
-	var char uint8
+// load level from the given reader
+func (e *Engine) ReadLevel(r io.Reader) error {
+	raw, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return e.LoadLevelString(string(raw))
+}
 
-	for _, line := range lines {
-		if len(line) > 0 && line[0] == '#' && len(line) > maxlen {
-			maxlen = len(line)
+// split function for a bufio.Scanner, that ends lines at "\n", "\r\n" and also at a single "\r",
+// as used by files from old Macs
+func ScanLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	for i, c := range data {
+		switch {
+		case c == '\n':
+			return i + 1, data[:i], nil
+		case c == '\r' && i+1 < len(data):
+			if data[i+1] == '\n' {
+				return i + 2, data[:i], nil
+			}
+			return i + 1, data[:i], nil
+		case c == '\r' && atEOF:
+			return i + 1, data[:i], nil
+		case c == '\r':
+			// wait for the next byte, it may be a "\n"
+			return 0, nil, nil
 		}
 	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// replace the line endings "\r\n" and "\r" by "\n"
+func NormaliseLineEndings(raw string) string {
+	return strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(raw)
+}
 
-	for _, line := range lines {
-		// filter empty lines and lines that do not start with '#'
-		if len(line) == 0 || line[0] != '#' {
+// load level from the given string, containing the rows of the level.
+// Rows start with a wall, optionally after some spaces. Run length encoded rows like '4#|#@$.#|4#' are expanded,
+// the column of a ParseError then refers to the expanded row. Overlong runs are refused with ErrRunLength.
+// Empty fields, that the figure can not reach, become void fields and the surface is trimmed to the fields,
+// that belong to the level.
+// Levels with structural errors are refused with a ValidationError.
+// The engine is only changed, if the level could be loaded without an error.
+func (e *Engine) LoadLevelString(raw string) error {
+	// files from windows end their lines with "\r\n", files from old Macs with "\r" only
+	raw = NormaliseLineEndings(raw)
+	// get single lines in an array
+	lines := strings.Split(raw, "\n")
+
+	// filter empty lines and lines that are no rows
+	rows := []string{}
+	lineNrs := []int{}
+	maxlen := 0
+	for l, line := range lines {
+		if !IsRow(line) {
 			continue
 		}
+		expanded, err := ExpandRows(line)
+		if err != nil {
+			return err
+		}
+		for _, row := range expanded {
+			rows = append(rows, row)
+			lineNrs = append(lineNrs, l+1)
+			if len(row) > maxlen {
+				maxlen = len(row)
+			}
+		}
+	}
+	if len(rows) == 0 {
+		return ErrNoLevel
+	}
+
+	// find the fields, that belong to the level and trim the rest
+	inner := innerFields(rows, maxlen)
+	outer := func(x int, y int) bool {
+		return !inner[y][x] && (x >= len(rows[y]) || rows[y][x] == ' ')
+	}
+	minX, minY, maxX, maxY := maxlen, len(rows), -1, -1
+	for y := range rows {
 		for x := 0; x < maxlen; x++ {
-			char = '#'
-			if x < len(line) {
-				char = line[x]
+			if outer(x, y) {
+				continue
+			}
+			minX, maxX = min(minX, x), max(maxX, x)
+			minY, maxY = min(minY, y), max(maxY, y)
+		}
+	}
+
+	le := NewEngine()
+	le.Id = e.Id
+	le.Surface = Surface{}
+	var field Field
+	boxId := 0
+	figures := 0
+	le.figPos = NewPoint(-1, -1) // no figure, until one is found
+	var char uint8
+
+	for y := minY; y <= maxY; y++ {
+		le.Surface = append(le.Surface, []Field{})
+		for x := minX; x <= maxX; x++ {
+			char = ' '
+			if x < len(rows[y]) {
+				char = rows[y][x]
 			}
+			p := NewPoint(x-minX, y-minY)
 			switch char {
 			case '#':
-				field = Field{true, false, false, EMPTY}
+				field = Field{true, false, false, EMPTY, false}
 			case ' ':
-				field = Field{false, false, false, EMPTY}
+				field = Field{false, false, false, EMPTY, false}
+				if outer(x, y) {
+					field = Field{true, false, false, EMPTY, true}
+				}
 			case '$':
 				boxId++
-				field = Field{false, false, false, boxId}
+				field = Field{false, false, false, boxId, false}
 			case '@':
-				field = Field{false, false, false, EMPTY}
-				e.figPos = NewPoint(x, y)
+				field = Field{false, false, false, EMPTY, false}
+				le.figPos = p
+				figures++
 			case '.':
-				field = Field{false, true, false, EMPTY}
+				field = Field{false, true, false, EMPTY, false}
 			case '*':
 				boxId++
-				field = Field{false, true, false, boxId}
+				field = Field{false, true, false, boxId, false}
 			case '+':
-				field = Field{false, true, false, EMPTY}
-				e.figPos = NewPoint(x, y)
+				field = Field{false, true, false, EMPTY, false}
+				le.figPos = p
+				figures++
 			default:
-				log.E(e.Id,"Unknown character in level file: '%c'", char)
+				return &ParseError{lineNrs[y], x + 1, char}
 			}
-			e.Surface[y] = append(e.Surface[y], field)
+			le.Surface[p.Y] = append(le.Surface[p.Y], field)
 			if field.Point {
-				e.points = append(e.points, NewPoint(x, y))
+				le.points = append(le.points, p)
 			}
 			if field.Box != EMPTY {
-				box := NewBox(NewPoint(x, y), boxId)
-				e.boxes[boxId] = &box
-				e.boxesOrdered[boxId] = &box
+				box := NewBox(p, boxId)
+				le.boxes[boxId] = &box
+				le.boxesOrdered[boxId] = &box
 			}
 		}
-		y++
-		e.Surface = append(e.Surface, []Field{})
 	}
-	// the last sub-array of Surface is always empty, so remove it...
-	if len(e.Surface[len(e.Surface)-1]) == 0 {
-		e.Surface = e.Surface[:len(e.Surface)-1]
+	problems := le.Validate()
+	if figures > 1 {
+		problems = append(problems, Problem{ERROR, fmt.Sprintf("level has %d figures", figures)})
 	}
-	return
+	if HasErrors(problems) {
+		return ValidationError(problems)
+	}
+	le.symmetries = le.Surface.Symmetries()
+	e.Replace(le)
+	return nil
+}
+
+// mark all fields of the rows, that the figure can reach when ignoring the boxes.
+// Without a figure, all fields that are no walls are marked.
+func innerFields(rows []string, width int) [][]bool {
+	inner := make([][]bool, len(rows))
+	queue := []Point{}
+	for y, row := range rows {
+		inner[y] = make([]bool, width)
+		for x := 0; x < len(row); x++ {
+			if row[x] == '@' || row[x] == '+' {
+				queue = append(queue, NewPoint(x, y))
+			}
+		}
+	}
+	if len(queue) == 0 {
+		for y, row := range rows {
+			for x := 0; x < len(row); x++ {
+				inner[y][x] = row[x] != '#'
+			}
+		}
+		return inner
+	}
+	queue = queue[:1]
+	inner[queue[0].Y][queue[0].X] = true
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		for dir := Direction(0); dir < 4; dir++ {
+			np := p.Add(dir.Point())
+			if np.Y < 0 || np.X < 0 || np.Y >= len(rows) || np.X >= width || inner[np.Y][np.X] {
+				continue
+			}
+			if np.X < len(rows[np.Y]) && rows[np.Y][np.X] == '#' {
+				continue
+			}
+			inner[np.Y][np.X] = true
+			queue = append(queue, np)
+		}
+	}
+	return inner
 }
 
 // loop over all points and check, if there is a box. Else return false
@@ -310,11 +573,13 @@ func (e *Engine) Won() bool {
 // print the current Surface
 func (e *Engine) Print() {
 	log.Lock <- 1
-	var x, y int8
-	for y = 0; y < int8(len(e.Surface)); y++ {
+	var x, y int
+	for y = 0; y < len(e.Surface); y++ {
 		log.A("%3d ", e.Id)
-		for x = 0; x < int8(len(e.Surface[y])); x++ {
+		for x = 0; x < len(e.Surface[y]); x++ {
 			switch field := e.Surface[y][x]; {
+			case field.Void:
+				log.A(" ")
 			case field.Wall:
 				log.A("#")
 			case e.figPos.X == x && e.figPos.Y == y:
@@ -354,7 +619,7 @@ func (e *Engine) Print() {
 func (e *Engine) GetBoxesAndX() (field []Point) {
 	field = append(field, e.figPos)
 
-	for i := int8(1); i <= int8(len(e.boxesOrdered)); i++ {
+	for i := 1; i <= len(e.boxesOrdered); i++ {
 		field = append(field, e.boxesOrdered[i].Pos)
 	}
 	return
@@ -376,8 +641,8 @@ func PrintInfo() {
 }
 
 // return number of boxes on the surface
-func (surface Surface) CountBoxes() int8 {
-	count := int8(0)
+func (surface Surface) CountBoxes() int {
+	count := 0
 	for y := 0; y < len(surface); y++ {
 		for x := 0; x < len(surface[y]); x++ {
 			if surface[y][x].Box != EMPTY {
@@ -390,31 +655,8 @@ func (surface Surface) CountBoxes() int8 {
 
 // check if the surface border was reached
 func (surface Surface) In(p Point) bool {
-	if p.Y < 0 || p.X < 0 || p.Y >= int8(len(surface)) || p.X >= int8(len(surface[p.Y])) {
+	if p.Y < 0 || p.X < 0 || p.Y >= len(surface) || p.X >= len(surface[p.Y]) {
 		return false
 	}
 	return true
 }
-
-// read from the specified file and return whole content in a string
-func readLevelAsString(filename string) (string, error) {
-	f, err := os.Open(filename)
-	if err != nil {
-		return "", err
-	}
-	defer f.Close()
-
-	var result []byte
-	buf := make([]byte, 100)
-	for {
-		n, err := f.Read(buf[0:])
-		result = append(result, buf[0:n]...)
-		if err != nil {
-			if err == io.EOF {
-				break
-			}
-			return "", err
-		}
-	}
-	return string(result), nil
-}
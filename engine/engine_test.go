@@ -1,7 +1,12 @@
 package engine
 
 import (
+	"encoding/json"
+	"math/rand"
+	"strings"
 	"testing"
+
+	"github.com/g3force/Go_Sokoban/internal/testlevel"
 )
 
 func TestCloneEngine(t *testing.T) {
@@ -40,4 +45,703 @@ func TestClonePoint(t *testing.T) {
 	if p2.X == 1 {
 		t.Error("ClonePoint: reference!")
 	}
-}
\ No newline at end of file
+}
+
+func TestSymmetries(t *testing.T) {
+	e := NewEngine()
+	testlevel.Load(t, &e, testlevel.Microban(t, 95))
+	if len(e.Symmetries()) != SYMMETRIES {
+		t.Errorf("expected %d symmetries, got %d", SYMMETRIES, len(e.Symmetries()))
+	}
+	e = NewEngine()
+	testlevel.Load(t, &e, "#####\n#@$.#\n#  ##\n#####")
+	if len(e.Symmetries()) != 1 || e.Symmetries()[0] != IDENTITY {
+		t.Errorf("expected only the identity, got %d", e.Symmetries())
+	}
+}
+
+func TestCanonicalBoxesAndX(t *testing.T) {
+	e1 := NewEngine()
+	testlevel.Load(t, &e1, testlevel.Microban(t, 95))
+	e2 := NewEngine()
+	testlevel.Load(t, &e2, "########\n#     @#\n# .$$. #\n# $..$ #\n# $..$ #\n# .$$. #\n#      #\n########")
+	if !samePoints(e1.CanonicalBoxesAndX(), e2.CanonicalBoxesAndX()) {
+		t.Error("mirrored constellations differ")
+	}
+	e2.Move(2)
+	if samePoints(e1.CanonicalBoxesAndX(), e2.CanonicalBoxesAndX()) {
+		t.Error("different constellations are equal")
+	}
+}
+
+func samePoints(a []Point, b []Point) bool {
+	return !LessPoints(a, b) && !LessPoints(b, a)
+}
+
+func TestWideLevel(t *testing.T) {
+	width := 300
+	row := "#@" + strings.Repeat(" ", width-5) + "$.#"
+	wall := strings.Repeat("#", width)
+	e := NewEngine()
+	testlevel.Load(t, &e, wall+"\n"+row+"\n"+wall)
+	if len(e.Surface[1]) != width {
+		t.Fatalf("expected %d columns, got %d", width, len(e.Surface[1]))
+	}
+	for i := 0; i < width-4; i++ {
+		if moved, _ := e.Move(0); !moved {
+			t.Fatalf("could not move in step %d", i)
+		}
+	}
+	if e.FigPos().X != width-3 || !e.Won() {
+		t.Errorf("box not pushed to the point, figure at %d", e.FigPos().X)
+	}
+	for i := 0; i < width-4; i++ {
+		e.UndoStep()
+	}
+	if e.FigPos().X != 1 || e.Won() {
+		t.Errorf("undo failed, figure at %d", e.FigPos().X)
+	}
+}
+
+func TestManyBoxes(t *testing.T) {
+	n := 200
+	level := strings.Repeat("#", n+2) + "\n" +
+		"#@" + strings.Repeat(" ", n-1) + "#\n" +
+		"#" + strings.Repeat("$", n) + "#\n" +
+		"#" + strings.Repeat(".", n) + "#\n" +
+		strings.Repeat("#", n+2)
+	e := NewEngine()
+	testlevel.Load(t, &e, level)
+	if len(e.Boxes()) != n || e.Surface.CountBoxes() != n {
+		t.Fatalf("expected %d boxes, got %d", n, len(e.Boxes()))
+	}
+	// push every box down onto its point
+	for x := 0; x < n; x++ {
+		if _, box := e.Move(1); box != x+1 {
+			t.Fatalf("expected to push box %d, pushed %d", x+1, box)
+		}
+		e.Move(3)
+		e.Move(0)
+	}
+	if !e.Won() {
+		t.Error("not all boxes on their points")
+	}
+	for len(e.History) > 0 {
+		e.UndoStep()
+	}
+	if e.Surface.CountBoxes() != n || e.Surface[2][n].Box != n || e.Won() {
+		t.Error("undo failed")
+	}
+}
+
+func TestReadLevel(t *testing.T) {
+	e := NewEngine()
+	if err := e.ReadLevel(strings.NewReader("; comment\n#####\n#@$.#\n#####\n")); err != nil {
+		t.Fatal(err)
+	}
+	if len(e.Surface) != 3 || len(e.Boxes()) != 1 || e.FigPos() != NewPoint(1, 1) {
+		t.Error("level not loaded correctly")
+	}
+}
+
+func TestLoadLevelError(t *testing.T) {
+	e := NewEngine()
+	testlevel.Load(t, &e, "#####\n#@$.#\n#####")
+	err := e.LoadLevelString("; comment\n#####\n#@x.#\n#####")
+	perr, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("expected a ParseError, got %v", err)
+	}
+	if perr.Line != 3 || perr.Column != 3 || perr.Char != 'x' {
+		t.Errorf("wrong position or character: %s", perr)
+	}
+	if len(e.Boxes()) != 1 {
+		t.Error("engine changed by an invalid level")
+	}
+	if e.LoadLevelString("no level") != ErrNoLevel {
+		t.Error("missing level not detected")
+	}
+	if e.LoadLevel("does/not/exist") == nil {
+		t.Error("missing file not detected")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	e := NewEngine()
+	levels := map[string]string{
+		"no figure":         "#####\n# $.#\n#####",
+		"two figures":       "#####\n#@$.#\n#@  #\n#####",
+		"boxes != points":   "######\n#@$$.#\n######",
+		"unreachable box":   "#########\n#@$.#$ .#\n#########",
+		"unreachable point": "########\n#@$$.#.#\n########",
+		"open level":        "#####\n#@$. \n#####",
+		"open row":          "##$     @\n .* $$. .\n",
+		"figure in a gap":   "##@##\n#$. #\n#####",
+	}
+	for name, level := range levels {
+		err := e.LoadLevelString(level)
+		if _, ok := err.(ValidationError); !ok {
+			t.Errorf("%s: expected a ValidationError, got %v", name, err)
+		}
+	}
+	// boxes on points behind walls are decoration
+	testlevel.Load(t, &e, "#######\n#@$.#*#\n#######")
+	if problems := e.Validate(); len(problems) != 1 || problems[0].Severity != WARNING {
+		t.Errorf("expected a single warning, got %v", problems)
+	}
+	// engines built without the loader are validated by the position of their figure
+	code := NewEngine()
+	code.Surface = e.Surface.Clone()
+	code.points = e.points
+	code.figPos = e.figPos
+	if problems := code.Validate(); HasErrors(problems) {
+		t.Errorf("unexpected problems %v", problems)
+	}
+	code.figPos = NewPoint(0, 0)
+	if problems := code.Validate(); !HasErrors(problems) {
+		t.Error("figure on a wall not detected")
+	}
+}
+
+func TestLoadLevelNormalised(t *testing.T) {
+	e := NewEngine()
+	// Arcade deluxe level 1-3, indented and surrounded by empty space
+	err := e.LoadLevelString("; 1-3\n\n    #######\n    #     #\n   ## .$. #\n   #@ $ $ #\n   #  .$. #\n   ##     #\n    #######   \n\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	width, height := e.Surface.Size()
+	if width != 8 || height != 7 {
+		t.Fatalf("surface not trimmed: %dx%d", width, height)
+	}
+	if !e.Surface[0][0].Void || !e.Surface[0][0].Wall || e.Surface[0][1].Void {
+		t.Error("outer field not marked as void")
+	}
+	if e.FigPos() != NewPoint(1, 3) || len(e.Boxes()) != 4 || len(e.Points()) != 4 {
+		t.Error("level not loaded correctly")
+	}
+	fields, _ := e.Surface.AmountOfFields()
+	if fields != 27 {
+		t.Errorf("expected 27 fields, got %d", fields)
+	}
+}
+
+func TestLURD(t *testing.T) {
+	e := NewEngine()
+	testlevel.Load(t, &e, testlevel.Corridor)
+	dirs := []Direction{0, 3, 2, 2, 2, 1, 0, 0}
+	lurd, err := e.FormatLURD(dirs)
+	if err != nil || lurd != "rullldRR" {
+		t.Errorf("unexpected LURD %q: %v", lurd, err)
+	}
+	if e.FigPos() != NewPoint(3, 2) {
+		t.Error("engine was changed while formatting")
+	}
+	if _, err := e.FormatLURD([]Direction{1}); err == nil || err.(*MoveError).Step != 1 {
+		t.Errorf("expected MoveError, got %v", err)
+	}
+	if CompressLURD(lurd) != "ru3ld2R" {
+		t.Errorf("unexpected compressed LURD %q", CompressLURD(lurd))
+	}
+	for _, raw := range []string{"rullldRR", "ru3ld2R", "r u 3l d 2(R)", "RULLLDRR"} {
+		parsed, err := ParseLURD(raw)
+		if err != nil || len(parsed) != len(dirs) {
+			t.Errorf("%q: unexpected moves %v: %v", raw, parsed, err)
+			continue
+		}
+		for i := range dirs {
+			if parsed[i] != dirs[i] {
+				t.Errorf("%q: unexpected moves %v", raw, parsed)
+				break
+			}
+		}
+	}
+	if parsed, err := ParseLURD("2(u3(lr))"); err != nil || len(parsed) != 14 {
+		t.Errorf("unexpected nested moves %v: %v", parsed, err)
+	}
+	for _, raw := range []string{"rx", "2(ur", "ur)", "3"} {
+		if _, err := ParseLURD(raw); err == nil {
+			t.Errorf("no error for %q", raw)
+		}
+	}
+	// short input must not expand into a huge list of moves
+	for _, raw := range []string{"999(999(99l))", "99999999999999999999l", "1000001l", "1000000l r"} {
+		if _, err := ParseLURD(raw); err != ErrLURDLength {
+			t.Errorf("%q: expected ErrLURDLength, got %v", raw, err)
+		}
+	}
+	if parsed, err := ParseLURD("1000000l"); err != nil || len(parsed) != MAX_LURD_LENGTH {
+		t.Errorf("unexpected %d moves: %v", len(parsed), err)
+	}
+	for _, dir := range dirs {
+		e.Move(dir)
+	}
+	if e.HistoryLURD() != lurd || !e.Won() {
+		t.Errorf("unexpected history %q", e.HistoryLURD())
+	}
+}
+
+func TestReplay(t *testing.T) {
+	e := NewEngine()
+	testlevel.Load(t, &e, testlevel.Corridor)
+	dirs, _ := ParseLURD("rullldRR")
+	if r, err := e.Replay(dirs); err != nil || r != (Replay{8, 2, true}) {
+		t.Errorf("unexpected replay %+v: %v", r, err)
+	}
+	if r, err := e.Replay(dirs[:7]); err != nil || r != (Replay{7, 1, false}) {
+		t.Errorf("unexpected replay %+v: %v", r, err)
+	}
+	dirs, _ = ParseLURD("ruuR")
+	r, err := e.Replay(dirs)
+	if moveErr, ok := err.(*MoveError); !ok || moveErr.Step != 3 || r.Moves != 2 {
+		t.Errorf("unexpected replay %+v: %v", r, err)
+	}
+	if e.FigPos() != NewPoint(3, 2) {
+		t.Error("engine was changed while replaying")
+	}
+}
+
+func TestRunLengthEncodedRows(t *testing.T) {
+	rows, err := ExpandRows("2-5#|--#@$.#|2-5#")
+	if err != nil || strings.Join(rows, "|") != "  #####|  #@$.#|  #####" {
+		t.Errorf("unexpected rows %q: %v", rows, err)
+	}
+	// a short line must not expand into a huge level
+	if _, err := ExpandRows("#|#@$.3000000#|#"); err != ErrRunLength {
+		t.Errorf("expected ErrRunLength, got %v", err)
+	}
+	if CompressRows(rows) != "2-5#|2-#@$.#|2-5#" {
+		t.Errorf("unexpected compressed rows %q", CompressRows(rows))
+	}
+	for line, row := range map[string]bool{"4#|#@$.#|4#": true, "-3#": true, "  # $ #": true, "12": false, "; 3 #": false} {
+		if IsRow(line) != row {
+			t.Errorf("%q: expected IsRow to be %v", line, row)
+		}
+	}
+	e := NewEngine()
+	testlevel.Load(t, &e, "; compact\n7#|#.$@$.#|7#\n")
+	if len(e.Surface) != 3 || len(e.Surface[0]) != 7 || len(e.Boxes()) != 2 {
+		t.Errorf("unexpected surface %dx%d with %d boxes", len(e.Surface[0]), len(e.Surface), len(e.Boxes()))
+	}
+	if err := e.LoadLevelString("#|#@$.99999999999999999999#|#"); err != ErrRunLength {
+		t.Errorf("expected ErrRunLength, got %v", err)
+	}
+	err = e.LoadLevelString("; compact\n#####|#@x.#|5#")
+	if perr, ok := err.(*ParseError); !ok || perr.Line != 2 || perr.Column != 3 {
+		t.Errorf("expected a ParseError in line 2, got %v", err)
+	}
+}
+
+func TestXSB(t *testing.T) {
+	e := NewEngine()
+	raw := "  #####\n###   #\n#.$@$.#\n#  *  #\n#######\n"
+	testlevel.Load(t, &e, raw)
+	if e.XSB() != raw {
+		t.Errorf("unexpected XSB:\n%s", e.XSB())
+	}
+	e.Move(2)
+	moved := "  #####\n###   #\n#*@ $.#\n#  *  #\n#######\n"
+	if e.XSB() != moved {
+		t.Errorf("unexpected XSB:\n%s", e.XSB())
+	}
+	ne := NewEngine()
+	if err := ne.LoadLevelString(e.XSB()); err != nil || ne.XSB() != e.XSB() {
+		t.Errorf("position not reloaded: %v", err)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	e := NewEngine()
+	testlevel.Load(t, &e, "  #####\n###   #\n#.$@$.#\n#  *  #\n#######")
+	e.Surface[1][5].Dead = true
+	e.Move(2)
+	e.Move(0)
+
+	raw, err := json.Marshal(&e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ne := NewEngine()
+	if err := json.Unmarshal(raw, &ne); err != nil {
+		t.Fatal(err)
+	}
+	if ne.XSB() != e.XSB() || len(ne.History) != 2 || ne.History[0] != e.History[0] || !ne.Surface[1][5].Dead {
+		t.Errorf("game state changed by JSON:\n%s%+v", ne.XSB(), ne.History)
+	}
+	for id, box := range e.Boxes() {
+		if nbox := ne.Boxes()[id]; nbox == nil || *nbox != *box {
+			t.Errorf("box %d changed by JSON", id)
+		}
+	}
+	for len(ne.History) > 0 {
+		ne.UndoStep()
+	}
+	if !strings.HasPrefix(ne.XSB(), "  #####\n###   #\n#.$@$.#") {
+		t.Errorf("history not restored:\n%s", ne.XSB())
+	}
+
+	raw, err = e.MarshalLevelJSON()
+	if err != nil || strings.Contains(string(raw), "history") || strings.Contains(string(raw), "dead") {
+		t.Errorf("unexpected level %s: %v", raw, err)
+	}
+	for _, invalid := range []string{
+		`{"version":2,"width":3,"height":1}`,
+		`{"version":1,"width":3,"height":1,"walls":[{"x":3,"y":0}]}`,
+		`{"version":1,"width":3,"height":1,"goals":[{"x":1,"y":0}],"figure":{"x":0,"y":0}}`,
+	} {
+		if err := json.Unmarshal([]byte(invalid), &ne); err == nil {
+			t.Errorf("no error for %s", invalid)
+		}
+	}
+	// histories, that do not lead to the position
+	testlevel.Load(t, &e, "#########\n#  @$.$.#\n#########")
+	raw, err = e.MarshalLevelJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	corridor := strings.TrimSuffix(string(raw), "}")
+	if err := json.Unmarshal([]byte(corridor+`,"history":[{"from":{"x":2,"y":1},"to":{"x":3,"y":1},"box":1}]}`), &ne); err != nil {
+		t.Errorf("valid history not accepted: %v", err)
+	}
+	for _, invalid := range []string{
+		corridor + `,"history":[{"from":{"x":4,"y":1},"to":{"x":5,"y":1},"box":1}]}`,
+		corridor + `,"history":[{"from":{"x":2,"y":1},"to":{"x":3,"y":1},"box":2}]}`,
+		corridor + `,"history":[{"from":{"x":4,"y":1},"to":{"x":3,"y":1},"box":0}]}`,
+		corridor + `,"dead":[{"x":4,"y":1}],"history":[{"from":{"x":2,"y":1},"to":{"x":3,"y":1},"box":1}]}`,
+		// the pushed box would be outside of the surface
+		`{"version":1,"width":3,"height":1,"goals":[{"x":0,"y":0}],"boxes":[{"id":1,"x":0,"y":0}],"figure":{"x":2,"y":0},` +
+			`"history":[{"from":{"x":1,"y":0},"to":{"x":2,"y":0},"box":1}]}`,
+	} {
+		if err := json.Unmarshal([]byte(invalid), &ne); err == nil {
+			t.Errorf("no error for %s", invalid)
+		}
+	}
+}
+
+func TestRedo(t *testing.T) {
+	e := NewEngine()
+	testlevel.Load(t, &e, testlevel.Corridor)
+	start := e.XSB()
+	dirs, _ := ParseLURD("rullldRR")
+	for _, dir := range dirs {
+		e.Move(dir)
+	}
+	solved := e.XSB()
+	if e.Undo(3) != 3 || e.RedoSteps() != 3 || e.HistoryLURD() != "rulll" {
+		t.Errorf("unexpected history after undo: %s", e.HistoryLURD())
+	}
+	if e.Redo(5) != 3 || e.XSB() != solved || !e.Won() {
+		t.Errorf("unexpected position after redo:\n%s", e.XSB())
+	}
+	e.Restart()
+	if e.XSB() != start || len(e.History) != 0 || e.RedoSteps() != len(dirs) {
+		t.Errorf("unexpected position after restart:\n%s", e.XSB())
+	}
+	e.Redo(2)
+	if e.HistoryLURD() != "ru" {
+		t.Errorf("unexpected history after redo: %s", e.HistoryLURD())
+	}
+	e.Move(1)
+	if e.RedoSteps() != 0 || e.Redo(1) != 0 {
+		t.Error("redo steps not cleared by a new move")
+	}
+	// a push onto a field, that was marked dead afterwards, can not be redone
+	testlevel.Load(t, &e, testlevel.Corridor)
+	e.Move(0)
+	e.Move(2)
+	e.Move(2)
+	e.Undo(2)
+	e.Surface[2][1].Dead = true
+	if e.Redo(2) != 1 || e.RedoSteps() != 1 || e.HistoryLURD() != "rl" {
+		t.Errorf("unexpected history after redo: %s", e.HistoryLURD())
+	}
+}
+
+func TestCounters(t *testing.T) {
+	e := NewEngine()
+	testlevel.Load(t, &e, testlevel.TwoBoxes)
+	left, right := e.Surface[2][2].Box, e.Surface[2][4].Box
+	dirs, _ := ParseLURD("RlLrrdrruLLu")
+	for _, dir := range dirs {
+		e.Move(dir)
+	}
+	if e.HistoryLURD() != "RlLrrdrruLLu" {
+		t.Fatalf("unexpected history %s", e.HistoryLURD())
+	}
+	if e.Moves() != 12 || e.Pushes() != 4 || e.BoxChanges() != 2 || e.BoxPushes(left) != 1 || e.BoxPushes(right) != 3 {
+		t.Errorf("unexpected counters: %d moves, %d pushes, %d changes, %d and %d pushes per box",
+			e.Moves(), e.Pushes(), e.BoxChanges(), e.BoxPushes(left), e.BoxPushes(right))
+	}
+	e.Undo(9)
+	if e.Moves() != 3 || e.Pushes() != 2 || e.BoxChanges() != 1 || e.BoxPushes(left) != 1 || e.BoxPushes(right) != 1 {
+		t.Errorf("unexpected counters after undo: %d moves, %d pushes, %d changes",
+			e.Moves(), e.Pushes(), e.BoxChanges())
+	}
+	e.Undo(1)
+	if e.BoxChanges() != 0 || e.BoxPushes(left) != 0 {
+		t.Errorf("unexpected counters after undo: %d changes", e.BoxChanges())
+	}
+	e.Redo(10)
+	raw, _ := json.Marshal(&e)
+	ne := NewEngine()
+	if err := json.Unmarshal(raw, &ne); err != nil {
+		t.Fatal(err)
+	}
+	if ne.Moves() != 12 || ne.Pushes() != 4 || ne.BoxChanges() != 2 || ne.BoxPushes(right) != 3 {
+		t.Error("counters not restored from JSON")
+	}
+	// counting works without NewEngine, too
+	var zero Engine
+	zero.count(HistoryType{NewPoint(1, 1), NewPoint(2, 1), 1})
+	if zero.Pushes() != 1 || zero.BoxPushes(1) != 1 {
+		t.Errorf("unexpected counters %d, %d", zero.Pushes(), zero.BoxPushes(1))
+	}
+}
+
+func TestWalkAndPushTo(t *testing.T) {
+	e := NewEngine()
+	testlevel.Load(t, &e, "########\n#  #   #\n# $@ $.#\n#   .  #\n########")
+	if !e.WalkTo(NewPoint(1, 3)) || e.FigPos() != NewPoint(1, 3) || e.HistoryLURD() != "dll" {
+		t.Errorf("unexpected walk %s", e.HistoryLURD())
+	}
+	if e.WalkTo(NewPoint(3, 1)) || e.WalkTo(NewPoint(0, 0)) || e.Moves() != 3 {
+		t.Error("walked to an unreachable field")
+	}
+	e.Restart()
+
+	box := e.Surface[2][2].Box
+	if !e.PushTo(box, NewPoint(4, 3)) || e.Surface[3][4].Box != box {
+		t.Fatalf("box not pushed:\n%s", e.XSB())
+	}
+	if e.HistoryLURD() != "dlluurDldRR" {
+		t.Errorf("unexpected path %s", e.HistoryLURD())
+	}
+	if e.PushTo(box, NewPoint(6, 1)) {
+		t.Error("pushed a box along another box")
+	}
+	e.Undo(e.Moves())
+	if e.Surface[2][2].Box != box || e.FigPos() != NewPoint(3, 2) {
+		t.Errorf("push not undone:\n%s", e.XSB())
+	}
+	// a path, that is blocked after some steps, is not walked at all
+	history, redo := e.HistoryLURD(), e.RedoSteps()
+	if e.moveAll([]Direction{2, 2, 2, 2, 2, 2, 2, 2}) || e.HistoryLURD() != history || e.RedoSteps() != redo {
+		t.Errorf("blocked path partially walked: %s", e.HistoryLURD())
+	}
+}
+
+// check, that the order of the boxes follows their positions by row and column
+func checkBoxOrder(t *testing.T, e *Engine) {
+	t.Helper()
+	for order := 1; order <= len(e.boxesOrdered); order++ {
+		box := e.boxesOrdered[order]
+		if box.Order != order || order > 1 && !e.boxesOrdered[order-1].Pos.Less(box.Pos) {
+			t.Fatalf("box %v has the wrong order %d:\n%s", box.Pos, order, e.XSB())
+		}
+		if id := e.Surface[box.Pos.Y][box.Pos.X].Box; e.boxes[id] != box {
+			t.Fatalf("box %v not on the surface", box.Pos)
+		}
+	}
+}
+
+func TestBoxOrder(t *testing.T) {
+	e := NewEngine()
+	testlevel.Load(t, &e, "#########\n#       #\n# $ $ $ #\n#   @   #\n# $ $ $ #\n# ..... #\n#   .   #\n#########")
+	// walk around and push the boxes horizontally and vertically, undo some of the steps in between
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		if rnd.Intn(5) == 0 {
+			e.UndoStep()
+		} else {
+			e.Move(Direction(rnd.Intn(4)))
+		}
+		checkBoxOrder(t, &e)
+	}
+	if e.Pushes() == 0 {
+		t.Fatal("no box pushed")
+	}
+	s := e.State()
+	e.Restart()
+	if err := e.Restore(s); err != nil {
+		t.Fatal(err)
+	}
+	checkBoxOrder(t, &e)
+	if e.State() != s {
+		t.Errorf("unexpected position after restore:\n%s", e.XSB())
+	}
+}
+
+func TestState(t *testing.T) {
+	e := NewEngine()
+	testlevel.Load(t, &e, testlevel.TwoBoxes)
+	start := e.State()
+	if start.Figure() != NewPoint(3, 2) || len(start.Boxes()) != 2 || start.Boxes()[0] != NewPoint(2, 2) {
+		t.Errorf("unexpected state %v %v", start.Figure(), start.Boxes())
+	}
+	if NewState(NewPoint(3, 2), []Point{NewPoint(4, 2), NewPoint(2, 2)}) != start {
+		t.Error("state depends on the order of the boxes")
+	}
+	e.Move(0)
+	e.Move(1)
+	moved := e.State()
+	if moved == start {
+		t.Error("state not changed by a push")
+	}
+	xsb := e.XSB()
+	visited := map[State]bool{start: true, moved: true}
+	if err := e.Restore(start); err != nil {
+		t.Fatal(err)
+	}
+	if e.State() != start || len(e.History) != 0 || e.Moves() != 0 || !visited[e.State()] {
+		t.Errorf("unexpected position after restore:\n%s", e.XSB())
+	}
+	if moved, _ := e.Move(0); !moved {
+		t.Error("box not movable after restore")
+	}
+	data, _ := moved.MarshalBinary()
+	var decoded State
+	if err := decoded.UnmarshalBinary(data); err != nil || decoded != moved {
+		t.Errorf("binary round trip failed: %v", err)
+	}
+	if err := e.Restore(decoded); err != nil || e.XSB() != xsb {
+		t.Errorf("unexpected position after restore: %v\n%s", err, e.XSB())
+	}
+	for _, s := range []State{
+		NewState(NewPoint(3, 2), []Point{NewPoint(2, 2)}),
+		NewState(NewPoint(0, 0), []Point{NewPoint(2, 2), NewPoint(4, 2)}),
+		NewState(NewPoint(2, 2), []Point{NewPoint(2, 2), NewPoint(4, 2)}),
+		NewState(NewPoint(3, 2), []Point{NewPoint(2, 2), NewPoint(2, 2)}),
+	} {
+		if err := e.Restore(s); err == nil {
+			t.Errorf("invalid state %v %v restored", s.Figure(), s.Boxes())
+		}
+	}
+	if e.XSB() != xsb {
+		t.Error("engine changed by an invalid state")
+	}
+	if err := decoded.UnmarshalBinary([]byte{0x80}); err == nil {
+		t.Error("invalid binary state decoded")
+	}
+	var empty State
+	if err := e.Restore(empty); err == nil || empty.Figure() != NewPoint(-1, -1) || len(empty.Boxes()) != 0 {
+		t.Error("empty state restored")
+	}
+}
+
+func TestBitboard(t *testing.T) {
+	e := NewEngine()
+	// wider than a single word, so the flood fill has to shift across words
+	rows := []string{
+		"######################################################################",
+		"#@ $ .                           $                                  .#",
+		"######################################################################",
+	}
+	testlevel.Load(t, &e, strings.Join(rows, "\n"))
+	b := e.Board()
+	if b.Width != 70 || b.Height != 3 || b.Boxes.Count() != 2 || b.Points.Count() != 2 {
+		t.Fatalf("unexpected board %dx%d", b.Width, b.Height)
+	}
+	if !b.Boxes.Has(b.Index(NewPoint(3, 1))) || b.Point(b.Index(NewPoint(3, 1))) != NewPoint(3, 1) {
+		t.Error("box not found on the board")
+	}
+	if reachable := b.Reachable(NewPoint(1, 1)); reachable.Count() != 2 || reachable.Next(0) != b.Index(NewPoint(1, 1)) {
+		t.Errorf("unexpected reachable fields %v", reachable)
+	}
+	// the flood fill must not wrap around into the next row
+	reachable := b.Reachable(NewPoint(4, 1))
+	if reachable.Count() != 29 || reachable.Has(b.Index(NewPoint(33, 1))) || reachable.Next(b.Index(NewPoint(33, 1))) != -1 {
+		t.Errorf("unexpected number of reachable fields: %d", reachable.Count())
+	}
+	if reachable = b.Reachable(NewPoint(40, 1)); reachable.Count() != 35 || reachable.Next(0) != b.Index(NewPoint(34, 1)) {
+		t.Errorf("unexpected number of reachable fields: %d", reachable.Count())
+	}
+	if b.Won() || !b.WithBoxes([]Point{NewPoint(5, 1), NewPoint(68, 1)}).Won() {
+		t.Error("unexpected won state")
+	}
+	if b.Free(b.Index(NewPoint(3, 1))) || !b.Free(b.Index(NewPoint(2, 1))) || b.Free(-1) || b.Free(b.Index(NewPoint(70, 1))) {
+		t.Error("unexpected free fields")
+	}
+	if b.Index(NewPoint(1, 1))+b.Offset(1) != b.Index(NewPoint(1, 2)) || b.Offset(NO_DIRECTION) != 0 {
+		t.Error("unexpected offsets")
+	}
+}
+
+// records all events as short strings
+type recorder struct {
+	NopListener
+	events []string
+}
+
+func (r *recorder) Moved(e *Engine, step HistoryType) {
+	r.events = append(r.events, "move")
+}
+
+func (r *recorder) BoxPushed(e *Engine, box int, from, to Point) {
+	r.events = append(r.events, "push")
+}
+
+func (r *recorder) BoxOnPoint(e *Engine, box int, p Point) {
+	r.events = append(r.events, "on")
+}
+
+func (r *recorder) BoxOffPoint(e *Engine, box int, p Point) {
+	r.events = append(r.events, "off")
+}
+
+func (r *recorder) Won(e *Engine) {
+	r.events = append(r.events, "won")
+}
+
+func (r *recorder) Undone(e *Engine, step HistoryType) {
+	r.events = append(r.events, "undo")
+}
+
+func TestListener(t *testing.T) {
+	e := NewEngine()
+	testlevel.Load(t, &e, "########\n#      #\n# @$ .*#\n########")
+	r := &recorder{}
+	e.AddListener(r)
+	e.Move(2)
+	e.Move(0)
+	e.Move(0)
+	e.Move(0)
+	if got := strings.Join(r.events, " "); got != "move move move push move push on won" {
+		t.Errorf("unexpected events: %s", got)
+	}
+	r.events = nil
+	e.Undo(2)
+	e.Redo(1)
+	if got := strings.Join(r.events, " "); got != "undo off undo move push" {
+		t.Errorf("unexpected events: %s", got)
+	}
+	if err := e.Restore(e.State()); err != nil {
+		t.Fatal(err)
+	}
+	ne := e.Clone()
+	ne.Move(0)
+	e.RemoveListener(r)
+	e.Move(0)
+	if len(r.events) != 5 {
+		t.Errorf("events of clones, restores or removed listeners received: %v", r.events)
+	}
+}
+
+func TestListenerAfterLoading(t *testing.T) {
+	e := NewEngine()
+	r := &recorder{}
+	e.AddListener(r)
+	testlevel.Load(t, &e, testlevel.Corridor)
+	e.Move(2)
+	raw, err := json.Marshal(&e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(raw, &e); err != nil {
+		t.Fatal(err)
+	}
+	e.Move(0)
+	e.Replace(NewEngine())
+	if got := strings.Join(r.events, " "); got != "move push move" || len(e.listeners) != 1 {
+		t.Errorf("unexpected events after loading: %s", got)
+	}
+}
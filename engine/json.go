@@ -0,0 +1,232 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// version of the JSON format, increased on every incompatible change
+const JSON_VERSION = 1
+
+// level or game state in JSON format. History and dead fields are only written for game states.
+type jsonGame struct {
+	Version int        `json:"version"`
+	Width   int        `json:"width"`
+	Height  int        `json:"height"`
+	Walls   []Point    `json:"walls"`
+	Void    []Point    `json:"void,omitempty"`
+	Goals   []Point    `json:"goals"`
+	Boxes   []jsonBox  `json:"boxes"`
+	Figure  Point      `json:"figure"`
+	Dead    []Point    `json:"dead,omitempty"`
+	History []jsonStep `json:"history,omitempty"`
+}
+
+type jsonBox struct {
+	Id int `json:"id"`
+	X  int `json:"x"`
+	Y  int `json:"y"`
+}
+
+type jsonStep struct {
+	From Point `json:"from"`
+	To   Point `json:"to"`
+	Box  int   `json:"box,omitempty"` // id of the pushed box, if any
+}
+
+// encode the current position as level in JSON format, without history and dead fields
+func (e *Engine) MarshalLevelJSON() ([]byte, error) {
+	return json.Marshal(e.jsonGame(false))
+}
+
+// encode the full game state in JSON format, including the history and the dead fields
+func (e *Engine) MarshalJSON() ([]byte, error) {
+	return json.Marshal(e.jsonGame(true))
+}
+
+func (e *Engine) jsonGame(state bool) (g jsonGame) {
+	g.Version = JSON_VERSION
+	g.Height = len(e.Surface)
+	g.Walls, g.Goals, g.Boxes = []Point{}, []Point{}, []jsonBox{}
+	for y := range e.Surface {
+		if len(e.Surface[y]) > g.Width {
+			g.Width = len(e.Surface[y])
+		}
+		for x, field := range e.Surface[y] {
+			p := NewPoint(x, y)
+			switch {
+			case field.Void:
+				g.Void = append(g.Void, p)
+			case field.Wall:
+				g.Walls = append(g.Walls, p)
+			}
+			if field.Point {
+				g.Goals = append(g.Goals, p)
+			}
+			if field.Box != EMPTY {
+				g.Boxes = append(g.Boxes, jsonBox{field.Box, x, y})
+			}
+			if state && field.Dead {
+				g.Dead = append(g.Dead, p)
+			}
+		}
+	}
+	g.Figure = e.figPos
+	if state {
+		for _, hist := range e.History {
+			g.History = append(g.History, jsonStep{hist.OldPos, hist.NewPos, hist.BoxMoved})
+		}
+	}
+	return
+}
+
+// decode a level or a game state in JSON format.
+// Levels with structural errors are refused with a ValidationError, like in LoadLevelString.
+// The engine is only changed, if the level could be decoded without an error.
+func (e *Engine) UnmarshalJSON(data []byte) error {
+	var g jsonGame
+	if err := json.Unmarshal(data, &g); err != nil {
+		return err
+	}
+	if g.Version < 1 || g.Version > JSON_VERSION {
+		return fmt.Errorf("unsupported version %d of the JSON format", g.Version)
+	}
+	if g.Width <= 0 || g.Height <= 0 {
+		return fmt.Errorf("invalid size %dx%d", g.Width, g.Height)
+	}
+
+	le := NewEngine()
+	le.Id = e.Id
+	le.Surface = make(Surface, g.Height)
+	for y := range le.Surface {
+		le.Surface[y] = make([]Field, g.Width)
+	}
+	field := func(p Point) (*Field, error) {
+		if !le.Surface.In(p) {
+			return nil, fmt.Errorf("field %d,%d is outside of the level", p.X, p.Y)
+		}
+		return &le.Surface[p.Y][p.X], nil
+	}
+	for _, p := range g.Walls {
+		f, err := field(p)
+		if err != nil {
+			return err
+		}
+		f.Wall = true
+	}
+	for _, p := range g.Void {
+		f, err := field(p)
+		if err != nil {
+			return err
+		}
+		f.Wall, f.Void = true, true
+	}
+	for _, p := range g.Goals {
+		f, err := field(p)
+		if err != nil {
+			return err
+		}
+		f.Point = true
+		le.points = append(le.points, p)
+	}
+	for _, p := range g.Dead {
+		f, err := field(p)
+		if err != nil {
+			return err
+		}
+		f.Dead = true
+	}
+	boxes := []*Box{}
+	for _, b := range g.Boxes {
+		p := NewPoint(b.X, b.Y)
+		f, err := field(p)
+		if err != nil {
+			return err
+		}
+		if b.Id <= EMPTY || le.boxes[b.Id] != nil {
+			return fmt.Errorf("invalid or duplicate box id %d", b.Id)
+		}
+		if f.Wall || f.Box != EMPTY {
+			return fmt.Errorf("box %d at %d,%d is not on an empty field", b.Id, b.X, b.Y)
+		}
+		f.Box = b.Id
+		box := NewBox(p, 0)
+		le.boxes[b.Id] = &box
+		boxes = append(boxes, &box)
+	}
+	// the order of the boxes is given by their position
+	sort.Slice(boxes, func(i, j int) bool {
+		return boxes[i].Pos.Less(boxes[j].Pos)
+	})
+	for i, box := range boxes {
+		box.Order = i + 1
+		le.boxesOrdered[box.Order] = box
+	}
+	f, err := field(g.Figure)
+	if err != nil {
+		return err
+	}
+	if f.Wall || f.Box != EMPTY {
+		return fmt.Errorf("figure at %d,%d is not on an empty field", g.Figure.X, g.Figure.Y)
+	}
+	le.figPos = g.Figure
+	for i, step := range g.History {
+		_, errFrom := field(step.From)
+		_, errTo := field(step.To)
+		dir := PointDirection(NewPoint(step.To.X-step.From.X, step.To.Y-step.From.Y))
+		if errFrom != nil || errTo != nil || dir == NO_DIRECTION {
+			return fmt.Errorf("invalid step %d in history", i+1)
+		}
+		if step.Box != EMPTY && le.boxes[step.Box] == nil {
+			return fmt.Errorf("unknown box %d in step %d of history", step.Box, i+1)
+		}
+		le.History = append(le.History, HistoryType{step.From, step.To, step.Box})
+		le.count(le.History[i])
+	}
+	if err := le.checkHistory(); err != nil {
+		return err
+	}
+	if problems := le.Validate(); HasErrors(problems) {
+		return ValidationError(problems)
+	}
+	le.symmetries = le.Surface.Symmetries()
+	e.Replace(le)
+	return nil
+}
+
+// check, that the history leads to the current position. The steps are undone on a clone back to the
+// initial position, checking that the figure and the pushed box are where each step says,
+// and redone afterwards, which has to result in the same steps and the same position.
+func (e *Engine) checkHistory() error {
+	ne := e.Clone()
+	for _, step := range e.History {
+		ne.History = append(ne.History, step)
+		ne.count(step)
+	}
+	for i := len(ne.History) - 1; i >= 0; i-- {
+		step := ne.History[i]
+		if ne.figPos != step.NewPos {
+			return fmt.Errorf("figure is not at %d,%d after step %d of history", step.NewPos.X, step.NewPos.Y, i+1)
+		}
+		if from := ne.Surface[step.OldPos.Y][step.OldPos.X]; from.Wall || from.Box != EMPTY {
+			return fmt.Errorf("field %d,%d of step %d of history is not empty", step.OldPos.X, step.OldPos.Y, i+1)
+		}
+		if step.BoxMoved != EMPTY {
+			box := step.NewPos.Add(NewPoint(step.NewPos.X-step.OldPos.X, step.NewPos.Y-step.OldPos.Y))
+			if !ne.Surface.In(box) || ne.Surface[box.Y][box.X].Box != step.BoxMoved {
+				return fmt.Errorf("box %d is not at %d,%d after step %d of history", step.BoxMoved, box.X, box.Y, i+1)
+			}
+		}
+		ne.UndoStep()
+	}
+	if ne.Redo(len(e.History)) != len(e.History) {
+		return fmt.Errorf("history can not be replayed")
+	}
+	for i, step := range ne.History {
+		if step != e.History[i] {
+			return fmt.Errorf("step %d of history can not be replayed", i+1)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package engine
+
+// receives the events of an engine. Embed NopListener to implement only some of the events.
+// Listeners are called synchronously after the surface was changed by a step or an undo.
+// Restart undoes every step and notifies about each of them, loading a level and restoring a state are silent.
+// Listeners stay registered, when another level is loaded into the engine.
+type Listener interface {
+	Moved(e *Engine, step HistoryType)            // the figure moved, with or without a box
+	BoxPushed(e *Engine, box int, from, to Point) // a box was pushed
+	BoxOnPoint(e *Engine, box int, p Point)       // a box was moved onto a point, also by an undo
+	BoxOffPoint(e *Engine, box int, p Point)      // a box was moved away from a point, also by an undo
+	Won(e *Engine)                                // all points are covered after a push
+	Undone(e *Engine, step HistoryType)           // a step was removed from the history
+}
+
+// listener, that ignores all events
+type NopListener struct{}
+
+func (NopListener) Moved(e *Engine, step HistoryType)            {}
+func (NopListener) BoxPushed(e *Engine, box int, from, to Point) {}
+func (NopListener) BoxOnPoint(e *Engine, box int, p Point)       {}
+func (NopListener) BoxOffPoint(e *Engine, box int, p Point)      {}
+func (NopListener) Won(e *Engine)                                {}
+func (NopListener) Undone(e *Engine, step HistoryType)           {}
+
+// add a listener, that receives all following events. Clones of the engine have no listeners.
+func (e *Engine) AddListener(l Listener) {
+	e.listeners = append(e.listeners, l)
+}
+
+// remove a listener, that was added before. Listeners are compared by ==, so pointers should be used.
+func (e *Engine) RemoveListener(l Listener) {
+	for i, listener := range e.listeners {
+		if listener == l {
+			e.listeners = append(e.listeners[:i:i], e.listeners[i+1:]...)
+			return
+		}
+	}
+}
+
+// replace the level, the position and the history by those of the other engine.
+// The listeners of this engine are kept, the listeners of the other engine are dropped.
+func (e *Engine) Replace(other Engine) {
+	other.listeners = e.listeners
+	*e = other
+}
+
+// notify the listeners about a step, that was added to the history
+func (e *Engine) notifyMove(step HistoryType) {
+	if len(e.listeners) == 0 {
+		return
+	}
+	for _, l := range e.listeners {
+		l.Moved(e, step)
+	}
+	if step.BoxMoved == EMPTY {
+		return
+	}
+	from := step.NewPos
+	to := from.Add(NewPoint(step.NewPos.X-step.OldPos.X, step.NewPos.Y-step.OldPos.Y))
+	for _, l := range e.listeners {
+		l.BoxPushed(e, step.BoxMoved, from, to)
+	}
+	e.notifyPoints(step.BoxMoved, from, to)
+	if e.Surface[to.Y][to.X].Point && e.Won() {
+		for _, l := range e.listeners {
+			l.Won(e)
+		}
+	}
+}
+
+// notify the listeners about a step, that was removed from the history
+func (e *Engine) notifyUndo(step HistoryType) {
+	if len(e.listeners) == 0 {
+		return
+	}
+	for _, l := range e.listeners {
+		l.Undone(e, step)
+	}
+	if step.BoxMoved == EMPTY {
+		return
+	}
+	from := step.NewPos.Add(NewPoint(step.NewPos.X-step.OldPos.X, step.NewPos.Y-step.OldPos.Y))
+	e.notifyPoints(step.BoxMoved, from, step.NewPos)
+}
+
+// notify the listeners, if a box, that was moved from one field to another, left or entered a point
+func (e *Engine) notifyPoints(box int, from Point, to Point) {
+	for _, l := range e.listeners {
+		if e.Surface[from.Y][from.X].Point {
+			l.BoxOffPoint(e, box, from)
+		}
+		if e.Surface[to.Y][to.X].Point {
+			l.BoxOnPoint(e, box, to)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package engine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// characters of the moves in LURD notation, indexed by direction. Pushes are written upper case.
+const lurdMoves = "rdlu"
+
+// largest number of moves, that run length encoded LURD may expand to
+const MAX_LURD_LENGTH = 1000000
+
+// run length encoded LURD expands to more than MAX_LURD_LENGTH moves
+var ErrLURDLength = fmt.Errorf("LURD expands to more than %d moves", MAX_LURD_LENGTH)
+
+// a move, that is not possible on the current surface
+type MoveError struct {
+	Step int       // number of the move, starting at 1
+	Dir  Direction // direction of the move
+}
+
+func (err *MoveError) Error() string {
+	return fmt.Sprintf("move %d (%c) is not possible", err.Step, err.Dir.LURD(false))
+}
+
+// character of the direction in LURD notation, upper case for a push
+func (dir Direction) LURD(push bool) byte {
+	c := lurdMoves[dir%4]
+	if push {
+		c = byte(unicode.ToUpper(rune(c)))
+	}
+	return c
+}
+
+// direction of a character in LURD notation, NO_DIRECTION for unknown characters
+func LURDDirection(c byte) Direction {
+	i := strings.IndexByte(lurdMoves, byte(unicode.ToLower(rune(c))))
+	if i < 0 {
+		return NO_DIRECTION
+	}
+	return Direction(i)
+}
+
+// format the moves in LURD notation. The moves are replayed on a clone of the engine to find the pushes.
+func (e *Engine) FormatLURD(dirs []Direction) (string, error) {
+	ne := e.Clone()
+	lurd := make([]byte, len(dirs))
+	for i, dir := range dirs {
+		moved, boxMoved := ne.Move(dir)
+		if !moved {
+			return string(lurd[:i]), &MoveError{i + 1, dir}
+		}
+		lurd[i] = dir.LURD(boxMoved != EMPTY)
+	}
+	return string(lurd), nil
+}
+
+// LURD notation of all moves within the history
+func (e *Engine) HistoryLURD() string {
+	lurd := make([]byte, len(e.History))
+	for i, hist := range e.History {
+		dir := PointDirection(NewPoint(hist.NewPos.X-hist.OldPos.X, hist.NewPos.Y-hist.OldPos.Y))
+		lurd[i] = dir.LURD(hist.BoxMoved != EMPTY)
+	}
+	return string(lurd)
+}
+
+// parse moves in LURD notation. The case of the characters is ignored, whitespace is skipped.
+// Run length encoded moves like '3l' or '2(ur)' are expanded, up to MAX_LURD_LENGTH moves.
+func ParseLURD(lurd string) ([]Direction, error) {
+	dirs, rest, err := parseLURD(lurd)
+	if err == nil && rest != "" {
+		err = fmt.Errorf("unexpected ')' in LURD")
+	}
+	return dirs, err
+}
+
+// parse until the end of the string or until a closing bracket.
+// Returns the remaining string, starting at the closing bracket, or an empty string.
+func parseLURD(lurd string) (dirs []Direction, rest string, err error) {
+	for len(lurd) > 0 {
+		if lurd[0] == ')' {
+			return dirs, lurd, nil
+		}
+		if unicode.IsSpace(rune(lurd[0])) {
+			lurd = lurd[1:]
+			continue
+		}
+		count := 1
+		digits := 0
+		for digits < len(lurd) && lurd[digits] >= '0' && lurd[digits] <= '9' {
+			digits++
+		}
+		if digits > 0 {
+			count, _ = strconv.Atoi(lurd[:digits])
+			lurd = lurd[digits:]
+			if len(lurd) == 0 {
+				return nil, "", fmt.Errorf("missing move after count %d in LURD", count)
+			}
+		}
+		var run []Direction
+		if lurd[0] == '(' {
+			var inner string
+			run, inner, err = parseLURD(lurd[1:])
+			if err != nil {
+				return nil, "", err
+			}
+			if inner == "" {
+				return nil, "", fmt.Errorf("missing ')' in LURD")
+			}
+			lurd = inner[1:]
+		} else {
+			dir := LURDDirection(lurd[0])
+			if dir == NO_DIRECTION {
+				return nil, "", fmt.Errorf("invalid character %q in LURD", lurd[0])
+			}
+			run = []Direction{dir}
+			lurd = lurd[1:]
+		}
+		// compare the count first, so the product can not overflow
+		if count > MAX_LURD_LENGTH || len(dirs)+count*len(run) > MAX_LURD_LENGTH {
+			return nil, "", ErrLURDLength
+		}
+		for i := 0; i < count; i++ {
+			dirs = append(dirs, run...)
+		}
+	}
+	return dirs, "", nil
+}
+
+// run length encode moves in LURD notation, runs of the same character are written like '3l'
+func CompressLURD(lurd string) string {
+	var b strings.Builder
+	for i := 0; i < len(lurd); {
+		j := i
+		for j < len(lurd) && lurd[j] == lurd[i] {
+			j++
+		}
+		if j-i > 1 {
+			b.WriteString(strconv.Itoa(j - i))
+		}
+		b.WriteByte(lurd[i])
+		i = j
+	}
+	return b.String()
+}
+
+// result of replaying a list of moves
+type Replay struct {
+	Moves  int  // number of moves of the figure
+	Pushes int  // number of moves, that pushed a box
+	Won    bool // all boxes are on points after the last move
+}
+
+// replay the moves on a clone of the engine. Returns a MoveError for the first move, that is not possible.
+func (e *Engine) Replay(dirs []Direction) (r Replay, err error) {
+	ne := e.Clone()
+	for i, dir := range dirs {
+		if moved, _ := ne.Move(dir); !moved {
+			err = &MoveError{i + 1, dir}
+			break
+		}
+	}
+	return Replay{ne.Moves(), ne.Pushes(), err == nil && ne.Won()}, err
+}
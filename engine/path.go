@@ -0,0 +1,116 @@
+package engine
+
+// position of the pushed box and the figure while searching a push path
+type pushPosition struct {
+	box Point
+	fig Point
+}
+
+// walk along a shortest path to the given field without moving any box.
+// All steps are recorded in the history. Returns false, if the field can not be reached.
+func (e *Engine) WalkTo(p Point) bool {
+	if !e.Surface.In(p) {
+		return false
+	}
+	prev := map[Point]Direction{e.figPos: NO_DIRECTION}
+	queue := []Point{e.figPos}
+	for len(queue) > 0 && !contains(prev, p) {
+		cur := queue[0]
+		queue = queue[1:]
+		for dir := Direction(0); dir < 4; dir++ {
+			np := cur.Add(dir.Point())
+			if !e.Surface.In(np) || contains(prev, np) {
+				continue
+			}
+			if field := e.Surface[np.Y][np.X]; field.Wall || field.Box != EMPTY {
+				continue
+			}
+			prev[np] = dir
+			queue = append(queue, np)
+		}
+	}
+	if !contains(prev, p) {
+		return false
+	}
+	dirs := []Direction{}
+	for cur := p; cur != e.figPos; {
+		dir := prev[cur]
+		dirs = append([]Direction{dir}, dirs...)
+		cur = cur.Add(Direction((dir + 2) % 4).Point())
+	}
+	return e.moveAll(dirs)
+}
+
+// push the box with the given id to the given field with as few moves as possible, without moving other boxes.
+// All steps are recorded in the history. Returns false, if the box can not be pushed to the field.
+func (e *Engine) PushTo(boxId int, p Point) bool {
+	box, ok := e.boxes[boxId]
+	if !ok || !e.Surface.In(p) {
+		return false
+	}
+	// a field is free for the box or the figure, if there is no wall and no other box
+	free := func(q Point) bool {
+		if !e.Surface.In(q) {
+			return false
+		}
+		field := e.Surface[q.Y][q.X]
+		return !field.Wall && (field.Box == EMPTY || field.Box == boxId)
+	}
+	start := pushPosition{box.Pos, e.figPos}
+	prev := map[pushPosition]pushPosition{start: start}
+	dirs := map[pushPosition]Direction{}
+	queue := []pushPosition{start}
+	var goal *pushPosition
+	for len(queue) > 0 && goal == nil {
+		cur := queue[0]
+		queue = queue[1:]
+		if cur.box == p {
+			goal = &cur
+			break
+		}
+		for dir := Direction(0); dir < 4; dir++ {
+			next := pushPosition{cur.box, cur.fig.Add(dir.Point())}
+			if next.fig == cur.box {
+				next.box = cur.box.Add(dir.Point())
+				if !free(next.box) || e.Surface[next.box.Y][next.box.X].Dead {
+					continue
+				}
+			} else if !free(next.fig) {
+				continue
+			}
+			if _, seen := prev[next]; seen {
+				continue
+			}
+			prev[next] = cur
+			dirs[next] = dir
+			queue = append(queue, next)
+		}
+	}
+	if goal == nil {
+		return false
+	}
+	path := []Direction{}
+	for cur := *goal; cur != start; cur = prev[cur] {
+		path = append([]Direction{dirs[cur]}, path...)
+	}
+	return e.moveAll(path)
+}
+
+// move in all given directions. The moves are checked on a clone first,
+// so the engine stays unchanged and false is returned, if any of the moves is not possible.
+func (e *Engine) moveAll(dirs []Direction) bool {
+	if _, err := e.Replay(dirs); err != nil {
+		return false
+	}
+	for _, dir := range dirs {
+		if moved, _ := e.Move(dir); !moved {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(prev map[Point]Direction, p Point) bool {
+	_, ok := prev[p]
+	return ok
+}
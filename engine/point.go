@@ -4,8 +4,8 @@ import ()
 
 // simple Point type
 type Point struct {
-	X int8
-	Y int8
+	X int `json:"x"`
+	Y int `json:"y"`
 }
 
 // add to points (their x and y)
@@ -17,13 +17,17 @@ func (p1 *Point) Add(p2 Point) Point {
 }
 
 func NewPoint(x int, y int) Point {
-	return Point{int8(x), int8(y)}
-}
-
-func NewPoint8(x int8, y int8) Point {
 	return Point{x, y}
 }
 
 func (p *Point) Clone() (Point) {
-	return NewPoint8((*p).X, (*p).Y)
-}
\ No newline at end of file
+	return NewPoint((*p).X, (*p).Y)
+}
+
+// compare two points by row and then by column
+func (p1 Point) Less(p2 Point) bool {
+	if p1.Y == p2.Y {
+		return p1.X < p2.X
+	}
+	return p1.Y < p2.Y
+}
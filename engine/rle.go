@@ -0,0 +1,83 @@
+package engine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// characters, that may appear in run length encoded rows
+const rleChars = "0123456789#$.*@+ -_|"
+
+// largest count of a run, so a short line can not expand into a huge level
+const MAX_RUN_LENGTH = 1000
+
+// a count within run length encoded rows is larger than MAX_RUN_LENGTH
+var ErrRunLength = fmt.Errorf("count in run length encoded row is larger than %d", MAX_RUN_LENGTH)
+
+// check, if the line contains rows of a level. Rows start with a wall, optionally after some spaces.
+// Run length encoded rows like '4#|#@$.#|4#' may also start with a count or with '-' or '_' for empty fields.
+func IsRow(line string) bool {
+	line = strings.TrimLeft(line, " ")
+	if strings.HasPrefix(line, "#") {
+		return true
+	}
+	return strings.Contains(line, "#") && strings.Trim(line, rleChars) == ""
+}
+
+// expand a line with run length encoded rows into the single rows.
+// A count repeats the following character, '|' separates rows and '-' or '_' are empty fields.
+// Lines with plain rows are returned unchanged. Counts larger than MAX_RUN_LENGTH are refused with ErrRunLength.
+func ExpandRows(line string) (rows []string, err error) {
+	for _, part := range strings.Split(line, "|") {
+		row := make([]byte, 0, len(part))
+		count := 0
+		for i := 0; i < len(part); i++ {
+			c := part[i]
+			if c >= '0' && c <= '9' {
+				if count = count*10 + int(c-'0'); count > MAX_RUN_LENGTH {
+					return nil, ErrRunLength
+				}
+				continue
+			}
+			if c == '-' || c == '_' {
+				c = ' '
+			}
+			if count == 0 {
+				count = 1
+			}
+			for ; count > 0; count-- {
+				row = append(row, c)
+			}
+		}
+		rows = append(rows, string(row))
+	}
+	return
+}
+
+// run length encode the rows into a single line like '4#|#@$.#|4#'.
+// Empty fields are written as '-', trailing empty fields are left out.
+func CompressRows(rows []string) string {
+	parts := make([]string, len(rows))
+	for y, row := range rows {
+		row = strings.TrimRight(row, " ")
+		var b strings.Builder
+		for i := 0; i < len(row); {
+			j := i
+			for j < len(row) && row[j] == row[i] {
+				j++
+			}
+			if j-i > 1 {
+				b.WriteString(strconv.Itoa(j - i))
+			}
+			if row[i] == ' ' {
+				b.WriteByte('-')
+			} else {
+				b.WriteByte(row[i])
+			}
+			i = j
+		}
+		parts[y] = b.String()
+	}
+	return strings.Join(parts, "|")
+}
@@ -0,0 +1,126 @@
+package engine
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// compact and immutable position of the figure and all boxes. States are comparable and can be used as map keys.
+// The boxes are sorted by row and column, so the ids of the boxes are not part of the state.
+type State struct {
+	key string // figure and sorted boxes, each as uvarint encoded x and y
+}
+
+// create a state from the position of the figure and the boxes in any order
+func NewState(fig Point, boxes []Point) State {
+	sorted := append([]Point{}, boxes...)
+	SortPoints(sorted)
+	key := make([]byte, 0, 4*(len(sorted)+1))
+	for _, p := range append([]Point{fig}, sorted...) {
+		key = binary.AppendUvarint(key, uint64(p.X))
+		key = binary.AppendUvarint(key, uint64(p.Y))
+	}
+	return State{string(key)}
+}
+
+// position of the figure and the boxes, sorted by row and column
+func (s State) points() (points []Point) {
+	key := []byte(s.key)
+	for len(key) > 0 {
+		x, n := binary.Uvarint(key)
+		y, m := binary.Uvarint(key[n:])
+		points = append(points, NewPoint(int(x), int(y)))
+		key = key[n+m:]
+	}
+	return
+}
+
+// position of the figure, -1,-1 for the zero State
+func (s State) Figure() Point {
+	if points := s.points(); len(points) > 0 {
+		return points[0]
+	}
+	return NewPoint(-1, -1)
+}
+
+// positions of the boxes, sorted by row and column. The zero State has no boxes.
+func (s State) Boxes() []Point {
+	if points := s.points(); len(points) > 0 {
+		return points[1:]
+	}
+	return nil
+}
+
+// encode the state for storing or transferring it
+func (s State) MarshalBinary() ([]byte, error) {
+	return []byte(s.key), nil
+}
+
+// decode a state, that was encoded by MarshalBinary
+func (s *State) UnmarshalBinary(data []byte) error {
+	var points []Point
+	for rest := data; len(rest) > 0; {
+		x, n := binary.Uvarint(rest)
+		if n <= 0 {
+			return errors.New("invalid state")
+		}
+		y, m := binary.Uvarint(rest[n:])
+		if m <= 0 {
+			return errors.New("invalid state")
+		}
+		points = append(points, NewPoint(int(x), int(y)))
+		rest = rest[n+m:]
+	}
+	if len(points) == 0 {
+		return errors.New("invalid state")
+	}
+	*s = NewState(points[0], points[1:])
+	return nil
+}
+
+// current state of the figure and the boxes
+func (e *Engine) State() State {
+	boxes := make([]Point, 0, len(e.boxes))
+	for _, box := range e.boxes {
+		boxes = append(boxes, box.Pos)
+	}
+	return NewState(e.figPos, boxes)
+}
+
+// move the figure and the boxes to the positions of the state. The history and the steps,
+// that could be redone, are cleared. The engine is only changed, if the state fits to the level.
+// Listeners are not notified, as the boxes do not move step by step.
+func (e *Engine) Restore(s State) error {
+	if s.key == "" {
+		return errors.New("state is empty")
+	}
+	fig, boxes := s.Figure(), s.Boxes()
+	if len(boxes) != len(e.boxes) {
+		return fmt.Errorf("state has %d boxes, but the level has %d", len(boxes), len(e.boxes))
+	}
+	for i, p := range append([]Point{fig}, boxes...) {
+		if !e.Surface.In(p) || e.Surface[p.Y][p.X].Wall {
+			return fmt.Errorf("field %d,%d of the state is no empty field", p.X, p.Y)
+		}
+		if i > 0 && p == fig || i > 1 && p == boxes[i-2] {
+			return fmt.Errorf("field %d,%d of the state is occupied twice", p.X, p.Y)
+		}
+	}
+	for _, box := range e.boxes {
+		e.Surface[box.Pos.Y][box.Pos.X].Box = EMPTY
+	}
+	// reOrderBoxes keeps the order of the boxes by row and column and the boxes of the state are sorted
+	// the same way, so every box gets the position with its order and the order stays valid
+	for id, box := range e.boxes {
+		box.Pos = boxes[box.Order-1]
+		e.Surface[box.Pos.Y][box.Pos.X].Box = id
+	}
+	e.figPos = fig
+	e.History = e.History[:0]
+	e.redo = e.redo[:0]
+	e.moves, e.pushes, e.boxChanges = 0, 0, 0
+	e.pushedBoxes = e.pushedBoxes[:0]
+	e.boxPushes = map[int]int{}
+	return nil
+}
@@ -0,0 +1,128 @@
+package engine
+
+import (
+	"sort"
+)
+
+// rotation or reflection of the surface
+type Symmetry int8
+
+const (
+	IDENTITY Symmetry = iota
+	ROTATE_90
+	ROTATE_180
+	ROTATE_270
+	MIRROR_HORIZONTAL // left <-> right
+	MIRROR_VERTICAL   // top <-> bottom
+	MIRROR_DIAGONAL   // top left <-> bottom right
+	MIRROR_ANTIDIAGONAL
+)
+
+// number of all possible symmetries of a rectangle
+const SYMMETRIES = 8
+
+// check, if the symmetry keeps the size of a surface with the given width and height
+func (s Symmetry) Fits(width int, height int) bool {
+	switch s {
+	case ROTATE_90, ROTATE_270, MIRROR_DIAGONAL, MIRROR_ANTIDIAGONAL:
+		return width == height
+	}
+	return true
+}
+
+// map a point of a surface with the width w and the height h
+func (s Symmetry) Transform(p Point, w int, h int) Point {
+	switch s {
+	case ROTATE_90:
+		return NewPoint(h-1-p.Y, p.X)
+	case ROTATE_180:
+		return NewPoint(w-1-p.X, h-1-p.Y)
+	case ROTATE_270:
+		return NewPoint(p.Y, w-1-p.X)
+	case MIRROR_HORIZONTAL:
+		return NewPoint(w-1-p.X, p.Y)
+	case MIRROR_VERTICAL:
+		return NewPoint(p.X, h-1-p.Y)
+	case MIRROR_DIAGONAL:
+		return NewPoint(p.Y, p.X)
+	case MIRROR_ANTIDIAGONAL:
+		return NewPoint(h-1-p.Y, w-1-p.X)
+	}
+	return p
+}
+
+// width and height of the surface, 0 if the rows differ in their length
+func (surface Surface) Size() (width int, height int) {
+	height = len(surface)
+	if height == 0 {
+		return
+	}
+	width = len(surface[0])
+	for y := 1; y < height; y++ {
+		if len(surface[y]) != width {
+			return 0, height
+		}
+	}
+	return
+}
+
+// find all symmetries, that map the walls and points of the surface onto themselves.
+// The identity is always included.
+func (surface Surface) Symmetries() []Symmetry {
+	symmetries := []Symmetry{IDENTITY}
+	width, height := surface.Size()
+	if width == 0 {
+		return symmetries
+	}
+	for s := Symmetry(1); s < SYMMETRIES; s++ {
+		if !s.Fits(width, height) {
+			continue
+		}
+		symmetric := true
+		for y := 0; y < height && symmetric; y++ {
+			for x := 0; x < width && symmetric; x++ {
+				t := s.Transform(NewPoint(x, y), width, height)
+				f1, f2 := surface[y][x], surface[t.Y][t.X]
+				symmetric = f1.Wall == f2.Wall && f1.Point == f2.Point
+			}
+		}
+		if symmetric {
+			symmetries = append(symmetries, s)
+		}
+	}
+	return symmetries
+}
+
+// return the figure and all boxes like GetBoxesAndX, but for the smallest of all symmetric constellations.
+// Constellations, that are mirrored or rotated versions of each other, have the same result.
+func (e *Engine) CanonicalBoxesAndX() (field []Point) {
+	width, height := e.Surface.Size()
+	for _, s := range e.symmetries {
+		candidate := []Point{s.Transform(e.figPos, width, height)}
+		for _, box := range e.boxes {
+			candidate = append(candidate, s.Transform(box.Pos, width, height))
+		}
+		SortPoints(candidate[1:])
+		if field == nil || LessPoints(candidate, field) {
+			field = candidate
+		}
+	}
+	return
+}
+
+// sort points by row and then by column
+func SortPoints(points []Point) {
+	sort.Slice(points, func(i, j int) bool {
+		return points[i].Less(points[j])
+	})
+}
+
+// compare two lists of points lexicographically
+func LessPoints(a []Point, b []Point) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return a[i].Less(b[i])
+		}
+	}
+	return len(a) < len(b)
+}
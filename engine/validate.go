@@ -0,0 +1,120 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+)
+
+// how bad a problem of a level is
+type Severity int8
+
+const (
+	WARNING Severity = iota // the level can be played, but looks strange
+	ERROR                   // the level can not be played
+)
+
+// a single problem found in a level
+type Problem struct {
+	Severity Severity
+	Message  string
+}
+
+// error of a level with structural problems, contains all problems found
+type ValidationError []Problem
+
+func (s Severity) String() string {
+	if s == ERROR {
+		return "error"
+	}
+	return "warning"
+}
+
+func (p Problem) String() string {
+	return p.Severity.String() + ": " + p.Message
+}
+
+func (err ValidationError) Error() string {
+	messages := []string{}
+	for _, p := range err {
+		if p.Severity == ERROR {
+			messages = append(messages, p.Message)
+		}
+	}
+	return "invalid level: " + strings.Join(messages, "; ")
+}
+
+// check, if there is at least one error within the problems
+func HasErrors(problems []Problem) bool {
+	for _, p := range problems {
+		if p.Severity == ERROR {
+			return true
+		}
+	}
+	return false
+}
+
+// check the structure of the level and return all problems found
+func (e *Engine) Validate() (problems []Problem) {
+	add := func(severity Severity, message string, args ...interface{}) {
+		problems = append(problems, Problem{severity, fmt.Sprintf(message, args...)})
+	}
+
+	fig := e.figPos
+	figure := e.Surface.In(fig) && !e.Surface[fig.Y][fig.X].Wall && e.Surface[fig.Y][fig.X].Box == EMPTY
+	if !figure {
+		add(ERROR, "level has no figure")
+	}
+	boxes, points := e.Surface.CountBoxes(), len(e.points)
+	if boxes != points {
+		add(ERROR, "level has %d boxes, but %d points", boxes, points)
+	} else if boxes == 0 {
+		add(WARNING, "level has no boxes")
+	}
+	if !figure {
+		return
+	}
+
+	// flood fill all fields, the figure can reach, when ignoring the boxes
+	reachable := make([][]bool, len(e.Surface))
+	for y := range e.Surface {
+		reachable[y] = make([]bool, len(e.Surface[y]))
+	}
+	enclosed := true
+	reachable[e.figPos.Y][e.figPos.X] = true
+	queue := []Point{e.figPos}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		for dir := Direction(0); dir < 4; dir++ {
+			np := p.Add(dir.Point())
+			if !e.Surface.In(np) {
+				enclosed = false
+				continue
+			}
+			if !e.Surface[np.Y][np.X].Wall && !reachable[np.Y][np.X] {
+				reachable[np.Y][np.X] = true
+				queue = append(queue, np)
+			}
+		}
+	}
+	if !enclosed {
+		add(ERROR, "figure is not enclosed by walls")
+	}
+	for y := range e.Surface {
+		for x, field := range e.Surface[y] {
+			if reachable[y][x] {
+				continue
+			}
+			switch {
+			case field.Box != EMPTY && field.Point:
+				// decoration, like in Microban 155
+				add(WARNING, "box on the point at %d,%d is outside of the reachable area", x, y)
+			case field.Box != EMPTY:
+				add(ERROR, "box at %d,%d is outside of the reachable area", x, y)
+			case field.Point:
+				add(ERROR, "point at %d,%d is outside of the reachable area", x, y)
+			}
+		}
+	}
+	return
+}
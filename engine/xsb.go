@@ -0,0 +1,46 @@
+package engine
+
+import (
+	"strings"
+)
+
+// rows of the surface in XSB format with the figure at the given position.
+// Void fields are written as spaces, dead fields as normal empty fields and trailing spaces are left out.
+func (surface Surface) XSBRows(fig Point) []string {
+	rows := make([]string, len(surface))
+	for y := range surface {
+		row := make([]byte, len(surface[y]))
+		for x, field := range surface[y] {
+			switch {
+			case field.Void:
+				row[x] = ' '
+			case field.Wall:
+				row[x] = '#'
+			case fig.X == x && fig.Y == y && field.Point:
+				row[x] = '+'
+			case fig.X == x && fig.Y == y:
+				row[x] = '@'
+			case field.Box != EMPTY && field.Point:
+				row[x] = '*'
+			case field.Box != EMPTY:
+				row[x] = '$'
+			case field.Point:
+				row[x] = '.'
+			default:
+				row[x] = ' '
+			}
+		}
+		rows[y] = strings.TrimRight(string(row), " ")
+	}
+	return rows
+}
+
+// rows of the current position in XSB format
+func (e *Engine) XSBRows() []string {
+	return e.Surface.XSBRows(e.figPos)
+}
+
+// the current position in XSB format, one row per line. It can be loaded again with LoadLevelString.
+func (e *Engine) XSB() string {
+	return strings.Join(e.XSBRows(), "\n") + "\n"
+}
@@ -1,10 +1,16 @@
 package main
 
 import (
+	"bufio"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 	"github.com/g3force/Go_Sokoban/ai"
 	"github.com/g3force/Go_Sokoban/engine"
+	"github.com/g3force/Go_Sokoban/generator"
+	"github.com/g3force/Go_Sokoban/level"
 	"github.com/g3force/Go_Sokoban/log"
 	"strconv"
 )
@@ -13,11 +19,24 @@ func main() {
 	log.DebugLevel = 4
 	runmode := false
 	single := true
-	level := "alevel"
+	levelFile := "alevel"
+	levelSelector := ""
 	straightAhead := false
 	outputFreq := int32(50000)
 	printSurface := false
 	threads := 1
+	generate := 0
+	genConfig := generator.NewConfig()
+	output := ""
+	annotate := ""
+	minimise := false
+	mcts := 0
+	verify := false
+	convert := ""
+	rle := false
+	resume := ""
+	solution := ""
+	maxStates := genConfig.MaxStates
 
 	e := engine.NewEngine()
 
@@ -28,7 +47,11 @@ func main() {
 				runmode = true
 			case "-l":
 				if len(os.Args) > i+1 {
-					level = os.Args[i+1]
+					levelFile = os.Args[i+1]
+				}
+			case "-n":
+				if len(os.Args) > i+1 {
+					levelSelector = os.Args[i+1]
 				}
 			case "-i":
 				engine.PrintInfo()
@@ -61,12 +84,131 @@ func main() {
 						threads = t
 					}
 				}
+			case "-g":
+				if len(os.Args) > i+1 {
+					n, err := strconv.Atoi(os.Args[i+1])
+					if err == nil {
+						generate = n
+					}
+				}
+			case "-seed":
+				if len(os.Args) > i+1 {
+					seed, err := strconv.ParseInt(os.Args[i+1], 10, 64)
+					if err == nil {
+						genConfig.Seed = seed
+					}
+				}
+			case "-o":
+				if len(os.Args) > i+1 {
+					output = os.Args[i+1]
+				}
+			case "-a":
+				if len(os.Args) > i+1 {
+					annotate = os.Args[i+1]
+				}
+			case "-mcts":
+				if len(os.Args) > i+1 {
+					n, err := strconv.Atoi(os.Args[i+1])
+					if err == nil {
+						mcts = n
+					}
+				}
+			case "-z":
+				minimise = true
+			case "-c":
+				if len(os.Args) > i+1 {
+					convert = os.Args[i+1]
+				}
+			case "-resume":
+				if len(os.Args) > i+1 {
+					resume = os.Args[i+1]
+				}
+			case "-rle":
+				rle = true
+			case "-v":
+				verify = true
+				if len(os.Args) > i+1 && !strings.HasPrefix(os.Args[i+1], "-") {
+					solution = os.Args[i+1]
+				}
+			case "-states":
+				if len(os.Args) > i+1 {
+					n, err := strconv.Atoi(os.Args[i+1])
+					if err == nil {
+						maxStates = n
+					}
+				}
 			}
 		}
 	}
+	genConfig.MaxStates = maxStates
 
-	e.LoadLevel(level)
-	log.I(e.Id, "Level: " + level)
+	if annotate != "" {
+		annotateLevels(annotate, output, maxStates)
+		return
+	}
+
+	if convert != "" {
+		convertCollection(levelFile, convert, rle)
+		return
+	}
+
+	if minimise {
+		minimiseLevel(levelFile, levelSelector, output, maxStates)
+		return
+	}
+
+	if generate > 0 {
+		generateLevels(genConfig, generate, output)
+		return
+	}
+
+	if resume != "" {
+		s, err := resumeSession(&e, resume)
+		if err != nil {
+			log.E(e.Id, "Could not resume session %s: %s", resume, err)
+			return
+		}
+		e.Print()
+		manualMode(&e, s.Level, s.Selector)
+		return
+	}
+
+	levelSolution, err := loadLevel(&e, levelFile, levelSelector)
+	if err != nil {
+		log.E(e.Id, "Could not load level %s: %s", levelFile, err)
+		return
+	}
+	for _, problem := range e.Validate() {
+		log.W(e.Id, "Level: %s", problem.Message)
+	}
+	log.I(e.Id, "Level: " + levelFile)
+
+	if verify {
+		if !verifySolution(e, solution, levelSolution) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if mcts > 0 {
+		mctsConfig := ai.NewMCTSConfig()
+		mctsConfig.Iterations = mcts
+		mctsConfig.Seed = genConfig.Seed
+		sol, won := ai.SolveMCTS(e, mctsConfig)
+		if won {
+			log.A("Solution found after %d playouts with %d pushes and %d moves.\n", sol.States, len(sol.Pushes), len(sol.Moves))
+		} else {
+			log.A("No solution found after %d playouts. Best partial solution with %d pushes and %d moves.\n", sol.States, len(sol.Pushes), len(sol.Moves))
+			reached := e.Clone()
+			for _, dir := range sol.Moves {
+				reached.Move(dir)
+			}
+			log.A("Reached position:\n%s", reached.XSB())
+		}
+		lurd, _ := e.FormatLURD(sol.Moves)
+		log.A("Path: %s\n", lurd)
+		return
+	}
 
 	if runmode {
 		ai.Run(e, single, outputFreq, printSurface, straightAhead, threads)
@@ -85,19 +227,325 @@ func main() {
 			ai.Run(e, single, outputFreq, printSurface, straightAhead, threads)
 			break
 		} else if choice == "m" {
-			log.A("Manual mode\n")
-			var input int
-			for {
-				fmt.Scanf("%d", &input)
-				if input >= 0 && input <= 3 {
-					e.Move(engine.Direction(input))
-					e.Print()
-				} else {
-					e.UndoStep()
-					e.Print()
+			manualMode(&e, levelFile, levelSelector)
+			break
+		}
+	}
+}
+
+// play the level manually. Besides moves, the current session can be saved and another session can be loaded.
+func manualMode(e *engine.Engine, levelFile string, levelSelector string) {
+	log.A("Manual mode\n")
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		input := strings.Fields(scanner.Text())
+		if len(input) == 0 {
+			continue
+		}
+		if n, err := strconv.Atoi(input[0]); err == nil && n >= 0 && n <= 3 {
+			e.Move(engine.Direction(n))
+		} else if dirs, err := engine.ParseLURD(input[0]); err == nil && len(dirs) > 0 {
+			for _, dir := range dirs {
+				e.Move(dir)
+			}
+		} else if input[0] == "undo" || input[0] == "redo" {
+			n := 1
+			if len(input) == 2 {
+				n, _ = strconv.Atoi(input[1])
+			}
+			if input[0] == "undo" {
+				e.Undo(n)
+			} else {
+				e.Redo(n)
+			}
+		} else if input[0] == "walk" && len(input) == 3 {
+			x, _ := strconv.Atoi(input[1])
+			y, _ := strconv.Atoi(input[2])
+			if !e.WalkTo(engine.NewPoint(x, y)) {
+				log.A("Can not walk to %d,%d\n", x, y)
+			}
+		} else if input[0] == "push" && len(input) == 5 {
+			coords := make([]int, 4)
+			for i := range coords {
+				coords[i], _ = strconv.Atoi(input[i+1])
+			}
+			box := engine.EMPTY
+			if p := engine.NewPoint(coords[0], coords[1]); e.Surface.In(p) {
+				box = e.Surface[p.Y][p.X].Box
+			}
+			if box == engine.EMPTY || !e.PushTo(box, engine.NewPoint(coords[2], coords[3])) {
+				log.A("Can not push a box from %d,%d to %d,%d\n", coords[0], coords[1], coords[2], coords[3])
+			}
+		} else if input[0] == "restart" {
+			e.Restart()
+		} else if input[0] == "p" {
+			log.A("%s", e.XSB())
+			continue
+		} else if input[0] == "save" && len(input) == 2 {
+			if err := level.SaveSession(input[1], level.NewSession(e, levelFile, levelSelector)); err != nil {
+				log.E(e.Id, "Could not save session: %s", err)
+			} else {
+				log.A("Session saved to %s\n", input[1])
+			}
+			continue
+		} else if input[0] == "load" && len(input) == 2 {
+			s, err := resumeSession(e, input[1])
+			if err != nil {
+				log.E(e.Id, "Could not load session: %s", err)
+				continue
+			}
+			levelFile, levelSelector = s.Level, s.Selector
+		} else {
+			e.UndoStep()
+		}
+		e.Print()
+		log.A("Moves: %s\n", engine.CompressLURD(e.HistoryLURD()))
+		log.A("%d moves, %d pushes, %d box changes\n", e.Moves(), e.Pushes(), e.BoxChanges())
+	}
+}
+
+// load the session from the given file into the engine, by loading its level and replaying its moves
+func resumeSession(e *engine.Engine, filename string) (level.Session, error) {
+	s, err := level.LoadSession(filename)
+	if err != nil {
+		return s, err
+	}
+	ne := engine.NewEngine()
+	ne.Id = e.Id
+	if _, err := loadLevel(&ne, s.Level, s.Selector); err != nil {
+		return s, err
+	}
+	if err := s.Replay(&ne); err != nil {
+		return s, err
+	}
+	e.Replace(ne)
+	return s, nil
+}
+
+// generate levels and write them to files with the given prefix, or to stdout if there is no prefix
+func generateLevels(c generator.Config, n int, prefix string) {
+	levels, err := generator.Generate(c, n)
+	if err != nil {
+		log.E(-1, "Could not generate levels: %s", err)
+		return
+	}
+	for i, l := range levels {
+		log.I(-1, "Level %d: %d pushes, %d moves", i, l.Pushes, l.Moves)
+		if prefix == "" {
+			l.Write(os.Stdout)
+			log.A("\n")
+			continue
+		}
+		f, err := os.Create(fmt.Sprintf("%s%03d", prefix, i))
+		if err != nil {
+			log.E(-1, "Could not create level file: %s", err)
+			return
+		}
+		l.Write(f)
+		f.Close()
+	}
+}
+
+// convert the collection into the format given by the suffix of the output file.
+// XSB collections are written with run length encoded rows, if rle is true.
+func convertCollection(input string, output string, rle bool) {
+	c, err := level.LoadCollection(input)
+	if err != nil {
+		log.E(-1, "Could not read level collection: %s", err)
+		return
+	}
+	if err := level.SaveCollection(output, c, rle); err != nil {
+		log.E(-1, "Could not write level collection: %s", err)
+		return
+	}
+	log.I(-1, "Converted %d levels to %s", len(c.Levels), output)
+}
+
+// load the level from the given file. Files ending with .lev are read with their meta data,
+// files ending with .json as level or game state in JSON format.
+// If a selector is given, the file is read as collection and the level with the selector as number or title is loaded.
+// Returns the solution stored with the level, if there is one.
+func loadLevel(e *engine.Engine, filename string, selector string) ([]engine.Direction, error) {
+	if strings.HasSuffix(filename, ".lev") {
+		l, err := level.LoadLev(filename)
+		if err != nil {
+			return nil, err
+		}
+		if err := l.CheckValidateCode(); err != nil {
+			log.W(e.Id, "%s: %v, the level may have been modified", filename, err)
+		}
+		if l.Title != "" {
+			log.I(e.Id, "Title: %s by %s", l.Title, l.Author)
+		}
+		if err := l.Load(e); err != nil {
+			return nil, err
+		}
+		if len(l.Solution) > 0 {
+			lurd, err := e.FormatLURD(l.Solution)
+			if err != nil {
+				log.W(e.Id, "Solution of the level is invalid: %s", err)
+			} else {
+				log.I(e.Id, "Level contains a solution with %d moves: %s", len(l.Solution), lurd)
+			}
+		}
+		return l.Solution, nil
+	}
+	if strings.HasSuffix(filename, ".json") {
+		raw, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		return nil, e.UnmarshalJSON(raw)
+	}
+	if selector == "" {
+		return nil, e.LoadLevel(filename)
+	}
+	c, err := level.LoadCollection(filename)
+	if err != nil {
+		return nil, err
+	}
+	l, err := c.Find(selector)
+	if err != nil {
+		return nil, err
+	}
+	return nil, l.Load(e)
+}
+
+// replay the solution on the level and report the result. The solution is given in LURD notation,
+// as list of directions (0-3) or as file containing one of both. Without a solution, the solution
+// stored with the level is verified. Returns true, if the solution solves the level.
+func verifySolution(e engine.Engine, solution string, levelSolution []engine.Direction) bool {
+	dirs := levelSolution
+	if solution != "" {
+		if raw, err := ioutil.ReadFile(solution); err == nil {
+			solution = string(raw)
+		}
+		var err error
+		if dirs, err = parseSolution(solution); err != nil {
+			log.E(e.Id, "Could not parse solution: %s", err)
+			return false
+		}
+	} else if dirs == nil {
+		log.E(e.Id, "No solution given and the level has no solution")
+		return false
+	}
+	r, err := e.Replay(dirs)
+	switch {
+	case err != nil:
+		log.A("Solution is invalid after %d moves and %d pushes: %s\n", r.Moves, r.Pushes, err)
+		return false
+	case !r.Won:
+		log.A("Solution ends unsolved after %d moves and %d pushes.\n", r.Moves, r.Pushes)
+		return false
+	}
+	log.A("Solution is valid with %d moves and %d pushes.\n", r.Moves, r.Pushes)
+	return true
+}
+
+// parse a solution in LURD notation or a list of directions like '[3 0 1]' or '3,0,1'
+func parseSolution(solution string) ([]engine.Direction, error) {
+	if strings.Trim(solution, "0123[], \t\r\n") != "" {
+		return engine.ParseLURD(solution)
+	}
+	dirs := []engine.Direction{}
+	for _, c := range solution {
+		if c >= '0' && c <= '3' {
+			dirs = append(dirs, engine.Direction(c-'0'))
+		}
+	}
+	return dirs, nil
+}
+
+// estimate the difficulty of all levels in the given directory or collection file and print them with their scores.
+// The scores are written into the comments of the levels, the annotated collection is saved to the output file, if given.
+func annotateLevels(path string, output string, maxStates int) {
+	var c level.Collection
+	if info, err := os.Stat(path); err == nil && !info.IsDir() {
+		c, err = level.LoadCollection(path)
+		if err != nil {
+			log.E(-1, "Could not read level collection: %s", err)
+			return
+		}
+	} else {
+		files, err := ioutil.ReadDir(path)
+		if err != nil {
+			log.E(-1, "Could not read level collection: %s", err)
+			return
+		}
+		for _, file := range files {
+			if file.IsDir() {
+				continue
+			}
+			filename := filepath.Join(path, file.Name())
+			if strings.HasSuffix(filename, ".lev") {
+				l, err := level.LoadLev(filename)
+				if err != nil {
+					log.W(-1, "Skipping %s: %s", file.Name(), err)
+					continue
 				}
+				if l.Title == "" {
+					l.Title = file.Name()
+				}
+				c.Levels = append(c.Levels, l)
+				continue
 			}
-			break
+			e := engine.NewEngine()
+			if err := e.LoadLevel(filename); err != nil {
+				log.W(-1, "Skipping %s: %s", file.Name(), err)
+				continue
+			}
+			c.Levels = append(c.Levels, level.Level{Title: file.Name(), Rows: e.XSBRows()})
 		}
 	}
+	log.A("%-30s %8s %8s %8s %8s %10s %6s\n", "Level", "Score", "Pushes", "Moves", "Changes", "States", "Dead")
+	for i := range c.Levels {
+		l := &c.Levels[i]
+		name := fmt.Sprintf("%d %s", i+1, l.Title)
+		e := engine.NewEngine()
+		if err := l.Load(&e); err != nil {
+			log.W(-1, "Skipping level %s: %s", name, err)
+			continue
+		}
+		d, solved := ai.EstimateDifficulty(e, maxStates)
+		if !solved {
+			log.A("%-30s %8s %8s %8s %8s %10d %6.2f\n", name, "unsolved", "-", "-", "-", d.States, d.DeadRatio)
+			continue
+		}
+		log.A("%-30s %8.2f %8d %8d %8d %10d %6.2f\n", name, d.Score, d.Pushes, d.Moves, d.BoxChanges, d.States, d.DeadRatio)
+		l.SetComment("Difficulty", fmt.Sprintf("%.2f (%d pushes, %d moves, %d box changes)", d.Score, d.Pushes, d.Moves, d.BoxChanges))
+	}
+	if output == "" {
+		return
+	}
+	if err := level.SaveCollection(output, c, false); err != nil {
+		log.E(-1, "Could not write level collection: %s", err)
+		return
+	}
+	log.I(-1, "Annotated %d levels in %s", len(c.Levels), output)
+}
+
+// minimise the given level and write it to the output file, or to stdout if there is no output file
+func minimiseLevel(filename string, selector string, output string, maxStates int) {
+	e := engine.NewEngine()
+	if _, err := loadLevel(&e, filename, selector); err != nil {
+		log.E(-1, "Could not load level: %s", err)
+		return
+	}
+	l, solved := generator.Minimise(e.XSBRows(), maxStates)
+	if !solved {
+		log.E(-1, "Level could not be solved within %d states", maxStates)
+		return
+	}
+	log.I(-1, "Minimised level: %d pushes, %d moves", l.Pushes, l.Moves)
+	if output == "" {
+		l.Write(os.Stdout)
+		return
+	}
+	f, err := os.Create(output)
+	if err != nil {
+		log.E(-1, "Could not create level file: %s", err)
+		return
+	}
+	defer f.Close()
+	l.Write(f)
 }
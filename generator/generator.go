@@ -0,0 +1,237 @@
+package generator
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"sort"
+	"strings"
+
+	"github.com/g3force/Go_Sokoban/ai"
+	"github.com/g3force/Go_Sokoban/engine"
+)
+
+// parameters for generating levels
+type Config struct {
+	Width      int   // width of the room, including the outer wall
+	Height     int   // height of the room, including the outer wall
+	Boxes      int   // number of boxes (and points)
+	Walls      int   // number of inner walls, that are placed randomly
+	Steps      int   // number of figure steps during the reverse play
+	Candidates int   // number of candidates that are scored for each level
+	MaxStates  int   // limit of states for the solver, 0 means no limit
+	Seed       int64 // seed of the random generator
+}
+
+// a generated level with its push optimal solution
+type Level struct {
+	Rows   []string // rows of the level in XSB format
+	Pushes int      // number of pushes of the push optimal solution
+	Moves  int      // number of moves of the push optimal solution
+}
+
+// a room, that the generator plays on
+type room struct {
+	fields [][]byte // '#' for walls, '.' for points and ' ' for empty fields
+	boxes  map[engine.Point]bool
+	fig    engine.Point
+}
+
+func NewConfig() Config {
+	return Config{
+		Width:      8,
+		Height:     8,
+		Boxes:      3,
+		Walls:      6,
+		Steps:      300,
+		Candidates: 20,
+		MaxStates:  200000,
+		Seed:       1,
+	}
+}
+
+// check, if the configuration allows to generate levels at all
+func (c Config) Validate() error {
+	if c.Width < 3 || c.Height < 3 {
+		return fmt.Errorf("room of %dx%d fields has no space within the outer wall", c.Width, c.Height)
+	}
+	if c.Boxes < 1 || c.Walls < 0 || c.Steps < 0 || c.Candidates < 1 || c.MaxStates < 0 {
+		return fmt.Errorf("invalid configuration %+v", c)
+	}
+	if inner := (c.Width - 2) * (c.Height - 2); c.Boxes+c.Walls+1 > inner {
+		return fmt.Errorf("%d boxes, %d walls and the figure do not fit into %d fields", c.Boxes, c.Walls, inner)
+	}
+	return nil
+}
+
+// generate n levels. For each level, the configured number of candidates is created by reverse play
+// and the candidates with the longest push optimal solution are returned, best level first.
+// Returns an error for impossible configurations or if none of the candidates was solvable.
+func Generate(c Config, n int) (levels []Level, err error) {
+	if err = c.Validate(); err != nil {
+		return
+	}
+	rnd := rand.New(rand.NewSource(c.Seed))
+	seen := map[string]bool{}
+	for i := 0; i < n*c.Candidates; i++ {
+		r := newRoom(c, rnd)
+		r.reversePlay(c.Steps, rnd)
+		level := Level{Rows: r.rows()}
+		key := strings.Join(level.Rows, "\n")
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		e := engine.NewEngine()
+		if e.LoadLevelString(key) != nil {
+			continue
+		}
+		sol, found := ai.SolvePushOptimal(e, c.MaxStates)
+		if !found || len(sol.Pushes) == 0 {
+			continue
+		}
+		level.Pushes = len(sol.Pushes)
+		level.Moves = len(sol.Moves)
+		levels = append(levels, level)
+	}
+	sort.SliceStable(levels, func(i, j int) bool {
+		if levels[i].Pushes == levels[j].Pushes {
+			return levels[i].Moves > levels[j].Moves
+		}
+		return levels[i].Pushes > levels[j].Pushes
+	})
+	if len(levels) == 0 && n > 0 {
+		return nil, fmt.Errorf("no solvable level found in %d attempts", n*c.Candidates)
+	}
+	if len(levels) > n {
+		levels = levels[:n]
+	}
+	return
+}
+
+// write the level in XSB format, one row per line
+func (l Level) Write(w io.Writer) error {
+	_, err := io.WriteString(w, strings.Join(l.Rows, "\n")+"\n")
+	return err
+}
+
+// create a random room with walls around and all boxes on their points
+func newRoom(c Config, rnd *rand.Rand) (r room) {
+	r.fields = make([][]byte, c.Height)
+	for y := range r.fields {
+		r.fields[y] = make([]byte, c.Width)
+		for x := range r.fields[y] {
+			if x == 0 || y == 0 || x == c.Width-1 || y == c.Height-1 {
+				r.fields[y][x] = '#'
+			} else {
+				r.fields[y][x] = ' '
+			}
+		}
+	}
+	// place inner walls, but never split the room. Config.Validate makes sure, that there is enough space.
+	for i := 0; i < c.Walls; i++ {
+		p := r.randomEmpty(rnd)
+		r.fields[p.Y][p.X] = '#'
+		if !r.connected() {
+			r.fields[p.Y][p.X] = ' '
+		}
+	}
+	r.boxes = map[engine.Point]bool{}
+	for i := 0; i < c.Boxes; i++ {
+		p := r.randomEmpty(rnd)
+		r.fields[p.Y][p.X] = '.'
+		r.boxes[p] = true
+	}
+	r.fig = r.randomEmpty(rnd)
+	return
+}
+
+// random field within the room, that is no wall and no point
+func (r *room) randomEmpty(rnd *rand.Rand) engine.Point {
+	empty := []engine.Point{}
+	for y := range r.fields {
+		for x := range r.fields[y] {
+			if r.fields[y][x] == ' ' {
+				empty = append(empty, engine.NewPoint(x, y))
+			}
+		}
+	}
+	return empty[rnd.Intn(len(empty))]
+}
+
+// check, if all fields, that are no walls, are connected with each other
+func (r *room) connected() bool {
+	var start engine.Point
+	total := 0
+	for y := range r.fields {
+		for x := range r.fields[y] {
+			if r.fields[y][x] != '#' {
+				start = engine.NewPoint(x, y)
+				total++
+			}
+		}
+	}
+	visited := map[engine.Point]bool{start: true}
+	queue := []engine.Point{start}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		for dir := engine.Direction(0); dir < 4; dir++ {
+			np := p.Add(dir.Point())
+			if r.fields[np.Y][np.X] != '#' && !visited[np] {
+				visited[np] = true
+				queue = append(queue, np)
+			}
+		}
+	}
+	return len(visited) == total
+}
+
+// check, if the figure or a box may enter the given field
+func (r *room) free(p engine.Point) bool {
+	return r.fields[p.Y][p.X] != '#' && !r.boxes[p]
+}
+
+// walk randomly through the room and pull boxes, that are behind the figure.
+// As every pull is a reversed push, the resulting level is always solvable.
+func (r *room) reversePlay(steps int, rnd *rand.Rand) {
+	for i := 0; i < steps; i++ {
+		dir := engine.Direction(rnd.Intn(4))
+		next := r.fig.Add(dir.Point())
+		if !r.free(next) {
+			continue
+		}
+		behind := r.fig.Add(engine.Direction((dir + 2) % 4).Point())
+		if r.boxes[behind] && rnd.Intn(2) == 0 {
+			delete(r.boxes, behind)
+			r.boxes[r.fig] = true
+		}
+		r.fig = next
+	}
+}
+
+// rows of the room in XSB format
+func (r *room) rows() []string {
+	rows := make([]string, len(r.fields))
+	for y := range r.fields {
+		row := make([]byte, len(r.fields[y]))
+		for x, field := range r.fields[y] {
+			p := engine.NewPoint(x, y)
+			switch {
+			case r.boxes[p] && field == '.':
+				row[x] = '*'
+			case r.boxes[p]:
+				row[x] = '$'
+			case p == r.fig && field == '.':
+				row[x] = '+'
+			case p == r.fig:
+				row[x] = '@'
+			default:
+				row[x] = field
+			}
+		}
+		rows[y] = string(row)
+	}
+	return rows
+}
@@ -0,0 +1,98 @@
+package generator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/g3force/Go_Sokoban/ai"
+	"github.com/g3force/Go_Sokoban/engine"
+	"github.com/g3force/Go_Sokoban/internal/testlevel"
+)
+
+func TestGenerateReproducible(t *testing.T) {
+	c := NewConfig()
+	c.Candidates = 3
+	levels1, err := Generate(c, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	levels2, _ := Generate(c, 2)
+	if len(levels1) != len(levels2) {
+		t.Fatal("same seed generated different number of levels")
+	}
+	for i := range levels1 {
+		if strings.Join(levels1[i].Rows, "\n") != strings.Join(levels2[i].Rows, "\n") {
+			t.Errorf("level %d differs for the same seed", i)
+		}
+	}
+}
+
+func TestGeneratedLevelSolvable(t *testing.T) {
+	c := NewConfig()
+	c.Candidates = 3
+	levels, err := Generate(c, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range levels {
+		e := engine.NewEngine()
+		testlevel.Load(t, &e, strings.Join(l.Rows, "\n"))
+		sol, found := ai.SolvePushOptimal(e, 0)
+		if !found {
+			t.Fatal("generated level is not solvable")
+		}
+		if len(sol.Pushes) != l.Pushes {
+			t.Errorf("pushes differ: %d != %d", len(sol.Pushes), l.Pushes)
+		}
+	}
+}
+
+func TestGenerateImpossibleConfig(t *testing.T) {
+	for _, change := range []func(c *Config){
+		func(c *Config) { c.Width = 2 },
+		func(c *Config) { c.Height = 0 },
+		func(c *Config) { c.Boxes = 0 },
+		func(c *Config) { c.Width, c.Height, c.Boxes, c.Walls = 4, 4, 4, 0 },
+		func(c *Config) { c.Boxes, c.Walls = 20, 20 },
+	} {
+		c := NewConfig()
+		change(&c)
+		if _, err := Generate(c, 1); err == nil {
+			t.Errorf("no error for configuration %+v", c)
+		}
+	}
+	// a single box without any space to move can not be pulled away from its point
+	c := NewConfig()
+	c.Width, c.Height, c.Walls = 3, 4, 0
+	c.Boxes = 1
+	if _, err := Generate(c, 1); err == nil {
+		t.Error("no error for a level without pushes")
+	}
+}
+
+func TestMinimise(t *testing.T) {
+	rows := []string{
+		"########",
+		"#      #",
+		"# $@.  #",
+		"#   *  #",
+		"########",
+	}
+	l, solved := Minimise(rows, 0)
+	if !solved {
+		t.Fatal("level not solved")
+	}
+	e := engine.NewEngine()
+	testlevel.Load(t, &e, strings.Join(rows, "\n"))
+	sol, _ := ai.SolvePushOptimal(e, 0)
+	if l.easier(Level{Pushes: len(sol.Pushes), Moves: len(sol.Moves)}) {
+		t.Errorf("minimised level got easier: %d pushes, %d moves instead of %d, %d",
+			l.Pushes, l.Moves, len(sol.Pushes), len(sol.Moves))
+	}
+	if strings.Count(strings.Join(l.Rows, ""), "*") != 0 {
+		t.Error("box on point was not removed")
+	}
+	if strings.Count(strings.Join(l.Rows, ""), "#") <= strings.Count(strings.Join(rows, ""), "#") {
+		t.Error("no field was turned into a wall")
+	}
+}
@@ -0,0 +1,128 @@
+package generator
+
+import (
+	"strings"
+
+	"github.com/g3force/Go_Sokoban/ai"
+	"github.com/g3force/Go_Sokoban/engine"
+)
+
+// reduce the level by turning empty fields into walls and by removing pairs of boxes and points.
+// A change is kept, if the level stays solvable and its push optimal solution does not get shorter,
+// neither in pushes nor in moves for the same number of pushes.
+// Returns false, if the given level itself can not be solved within maxStates states.
+func Minimise(rows []string, maxStates int) (level Level, solved bool) {
+	fields := make([][]byte, len(rows))
+	for y, row := range rows {
+		fields[y] = []byte(row)
+	}
+	level, solved = solveFields(fields, maxStates)
+	if !solved {
+		return
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, candidate := range reductions(fields) {
+			l, ok := solveFields(candidate, maxStates)
+			if ok && !l.easier(level) {
+				fields, level = candidate, l
+				changed = true
+				break
+			}
+		}
+	}
+	return
+}
+
+// check, if the solution of the level is shorter than the one of the other level.
+// Pushes are compared first, moves only for the same number of pushes.
+func (l Level) easier(other Level) bool {
+	if l.Pushes != other.Pushes {
+		return l.Pushes < other.Pushes
+	}
+	return l.Moves < other.Moves
+}
+
+// solve the level given by its fields
+func solveFields(fields [][]byte, maxStates int) (level Level, solved bool) {
+	level.Rows = make([]string, len(fields))
+	for y := range fields {
+		level.Rows[y] = string(fields[y])
+	}
+	e := engine.NewEngine()
+	if e.LoadLevelString(strings.Join(level.Rows, "\n")) != nil {
+		return
+	}
+	sol, solved := ai.SolvePushOptimal(e, maxStates)
+	level.Pushes = len(sol.Pushes)
+	level.Moves = len(sol.Moves)
+	return
+}
+
+// all levels, that are reduced by a single change
+func reductions(fields [][]byte) (candidates [][][]byte) {
+	inner := innerFields(fields)
+	var boxes, points []engine.Point
+	for y := range fields {
+		for x, field := range fields[y] {
+			p := engine.NewPoint(x, y)
+			if field == ' ' && inner[p] {
+				candidate := copyFields(fields)
+				candidate[y][x] = '#'
+				candidates = append(candidates, candidate)
+			}
+			if field == '$' || field == '*' {
+				boxes = append(boxes, p)
+			}
+			if field == '.' || field == '*' || field == '+' {
+				points = append(points, p)
+			}
+		}
+	}
+	for _, box := range boxes {
+		for _, point := range points {
+			candidate := copyFields(fields)
+			candidate[box.Y][box.X] = map[byte]byte{'$': ' ', '*': '.'}[candidate[box.Y][box.X]]
+			candidate[point.Y][point.X] = map[byte]byte{'.': ' ', '*': '$', '+': '@'}[candidate[point.Y][point.X]]
+			candidates = append(candidates, candidate)
+		}
+	}
+	return
+}
+
+// all fields, the figure can reach when ignoring the boxes
+func innerFields(fields [][]byte) map[engine.Point]bool {
+	inner := map[engine.Point]bool{}
+	queue := []engine.Point{}
+	for y := range fields {
+		for x, field := range fields[y] {
+			if field == '@' || field == '+' {
+				queue = append(queue, engine.NewPoint(x, y))
+				inner[queue[0]] = true
+			}
+		}
+	}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		for dir := engine.Direction(0); dir < 4; dir++ {
+			np := p.Add(dir.Point())
+			if np.Y < 0 || np.X < 0 || np.Y >= len(fields) || np.X >= len(fields[np.Y]) {
+				continue
+			}
+			if fields[np.Y][np.X] != '#' && !inner[np] {
+				inner[np] = true
+				queue = append(queue, np)
+			}
+		}
+	}
+	return inner
+}
+
+func copyFields(fields [][]byte) [][]byte {
+	nf := make([][]byte, len(fields))
+	for y := range fields {
+		nf[y] = append([]byte{}, fields[y]...)
+	}
+	return nf
+}
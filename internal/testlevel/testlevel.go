@@ -0,0 +1,61 @@
+// levels and helpers, that are shared by the tests of several packages
+package testlevel
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+// bundled Microban collection, relative to the directory of a package. It ends its lines with "\r" only.
+const MicrobanFile = "../res/level/level-sets/Microban.xsb.txt"
+
+// one box left of the figure, that has to be pushed two fields to the right onto the point
+const Corridor = "#######\n#     #\n# $@. #\n#######"
+
+// two boxes left and right of the figure and two points
+const TwoBoxes = "########\n#      #\n# $@$. #\n#   .  #\n########"
+
+// anything, that can load a level from a string, like *engine.Engine
+type Loader interface {
+	LoadLevelString(level string) error
+}
+
+// load the level and stop the test, if it can not be loaded
+func Load(t testing.TB, l Loader, level string) {
+	t.Helper()
+	if err := l.LoadLevelString(level); err != nil {
+		t.Fatalf("level not loaded: %v", err)
+	}
+}
+
+// rows of the level with the given number from the bundled Microban collection, one row per line.
+// The collection is not parsed by the level package, so the engine can use it in its own tests.
+// Level 95 has all eight symmetries.
+func Microban(t testing.TB, n int) string {
+	t.Helper()
+	raw, err := ioutil.ReadFile(MicrobanFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(string(raw)), "\n")
+	header := fmt.Sprintf("; %d", n)
+	for i, line := range lines {
+		if strings.TrimSpace(line) != header {
+			continue
+		}
+		rows := []string{}
+		for _, row := range lines[i+1:] {
+			if strings.HasPrefix(row, ";") {
+				break
+			}
+			if strings.TrimSpace(row) != "" {
+				rows = append(rows, row)
+			}
+		}
+		return strings.Join(rows, "\n")
+	}
+	t.Fatalf("level %d not found in %s", n, MicrobanFile)
+	return ""
+}
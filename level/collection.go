@@ -0,0 +1,298 @@
+package level
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/g3force/Go_Sokoban/engine"
+)
+
+// a single level with its meta data
+type Level struct {
+	Title        string
+	Author       string
+	Comment      string             // all other lines describing the level, separated by newlines
+	Version      string             // version of the level, only used in .lev files
+	ValidateCode string             // code to detect modified .lev files
+	Rows         []string           // rows of the level in XSB format
+	Solution     []engine.Direction // moves of the figure, that solve the level, if known
+	Extra        []string           // lines with unknown keys like 'dir=25', only used in .lev files
+}
+
+// an ordered list of levels with the meta data of the whole collection
+type Collection struct {
+	Title   string
+	Author  string
+	Comment string
+	Levels  []Level
+}
+
+// the collection does not contain any level
+var ErrNoLevels = errors.New("no levels found in collection")
+
+// load a collection of levels from the specified file.
+// Files ending with .slc are read in SLC format, all other files in XSB format.
+func LoadCollection(filename string) (c Collection, err error) {
+	if strings.HasSuffix(strings.ToLower(filename), ".slc") {
+		return LoadSLC(filename)
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	return ReadCollection(f)
+}
+
+// read a collection of levels in XSB format.
+// Levels are separated by lines, that are no rows. The lines before a level describe it:
+// lines starting with ';' are comments, where the last one is used as title,
+// a line in single quotes is the title and lines like 'Title: ...' or 'Author: ...' set the meta data.
+// Such key lines directly after a level belong to the level before.
+// Lines before the first level, that are separated by an empty line, describe the collection.
+func ReadCollection(r io.Reader) (c Collection, err error) {
+	var pending []string // lines since the end of the last level
+	var rows []string    // rows of the current level
+	afterLevel := false  // lines directly after a level, before an empty line
+
+	finishLevel := func() {
+		groups := groupLines(pending)
+		l := Level{Rows: rows}
+		if len(groups) > 0 {
+			describe(&l.Title, &l.Author, &l.Comment, groups[len(groups)-1], true)
+			groups = groups[:len(groups)-1]
+		}
+		for _, group := range groups {
+			if len(c.Levels) == 0 {
+				describe(&c.Title, &c.Author, &c.Comment, group, false)
+			} else {
+				describe(&l.Title, &l.Author, &l.Comment, group, false)
+			}
+		}
+		c.Levels = append(c.Levels, l)
+		pending, rows = nil, nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Split(engine.ScanLines)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case IsRow(line):
+			expanded, err := engine.ExpandRows(line)
+			if err != nil {
+				return c, err
+			}
+			rows = append(rows, expanded...)
+			continue
+		case len(rows) > 0:
+			finishLevel()
+			afterLevel = true
+		}
+		if strings.TrimSpace(line) == "" {
+			afterLevel = false
+		}
+		if afterLevel {
+			l := &c.Levels[len(c.Levels)-1]
+			describe(&l.Title, &l.Author, &l.Comment, []string{line}, false)
+			continue
+		}
+		pending = append(pending, line)
+	}
+	if err = scanner.Err(); err != nil {
+		return
+	}
+	if len(rows) > 0 {
+		finishLevel()
+	}
+	if len(c.Levels) == 0 {
+		err = ErrNoLevels
+	}
+	return
+}
+
+// save the collection to the specified file.
+// Files ending with .slc are written in SLC format, all other files in XSB format,
+// with run length encoded rows if rle is true.
+func SaveCollection(filename string, c Collection, rle bool) error {
+	if strings.HasSuffix(strings.ToLower(filename), ".slc") {
+		return SaveSLC(filename, c)
+	}
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if rle {
+		return WriteCollectionRLE(f, c)
+	}
+	return WriteCollection(f, c)
+}
+
+// write the collection in XSB format, that can be read by ReadCollection.
+// The meta data is written as key lines like 'Title: ...' before the collection and before each level.
+func WriteCollection(w io.Writer, c Collection) error {
+	return writeCollection(w, c, false)
+}
+
+// write the collection in XSB format like WriteCollection,
+// but each level as a single run length encoded line like '4#|#@$.#|4#'
+func WriteCollectionRLE(w io.Writer, c Collection) error {
+	return writeCollection(w, c, true)
+}
+
+func writeCollection(w io.Writer, c Collection, rle bool) error {
+	b := bufio.NewWriter(w)
+	if writeDescription(b, c.Title, c.Author, c.Comment) {
+		fmt.Fprintln(b)
+	}
+	for _, l := range c.Levels {
+		writeDescription(b, l.Title, l.Author, l.Comment)
+		if rle {
+			fmt.Fprintln(b, engine.CompressRows(l.Rows))
+		} else {
+			for _, row := range l.Rows {
+				fmt.Fprintln(b, row)
+			}
+		}
+		fmt.Fprintln(b)
+	}
+	return b.Flush()
+}
+
+// write title, author and comment as key lines. Returns false, if there was nothing to write.
+func writeDescription(w io.Writer, title string, author string, comment string) bool {
+	if title != "" {
+		fmt.Fprintf(w, "Title: %s\n", title)
+	}
+	if author != "" {
+		fmt.Fprintf(w, "Author: %s\n", author)
+	}
+	if comment != "" {
+		for _, line := range strings.Split(comment, "\n") {
+			fmt.Fprintf(w, "Comment: %s\n", line)
+		}
+	}
+	return title != "" || author != "" || comment != ""
+}
+
+// check, if the line is a row of a level. Rows start with a wall, optionally after some spaces,
+// or are run length encoded like '4#|#@$.#|4#'.
+func IsRow(line string) bool {
+	return engine.IsRow(line)
+}
+
+// find a level by its number (starting at 1) or by its title
+func (c Collection) Find(selector string) (Level, error) {
+	if n, err := strconv.Atoi(selector); err == nil && n >= 1 && n <= len(c.Levels) {
+		return c.Levels[n-1], nil
+	}
+	for _, l := range c.Levels {
+		if l.Title == selector {
+			return l, nil
+		}
+	}
+	for _, l := range c.Levels {
+		if strings.EqualFold(l.Title, selector) {
+			return l, nil
+		}
+	}
+	return Level{}, fmt.Errorf("level %q not found in collection", selector)
+}
+
+// set a line like 'key: value' in the comment of the level. An existing line with the same key is replaced.
+func (l *Level) SetComment(key string, value string) {
+	line := key + ": " + value
+	lines := []string{}
+	if l.Comment != "" {
+		lines = strings.Split(l.Comment, "\n")
+	}
+	for i := range lines {
+		if strings.HasPrefix(lines[i], key+":") {
+			lines[i] = line
+			l.Comment = strings.Join(lines, "\n")
+			return
+		}
+	}
+	l.Comment = strings.Join(append(lines, line), "\n")
+}
+
+// load the level into the given engine
+func (l Level) Load(e *engine.Engine) error {
+	return e.LoadLevelString(strings.Join(l.Rows, "\n"))
+}
+
+// split the lines into groups, that are separated by empty lines
+func groupLines(lines []string) (groups [][]string) {
+	var group []string
+	for _, line := range append(lines, "") {
+		if strings.TrimSpace(line) != "" {
+			group = append(group, line)
+		} else if len(group) > 0 {
+			groups = append(groups, group)
+			group = nil
+		}
+	}
+	return
+}
+
+// set title, author and comment from the given lines.
+// If titled is true, the last comment line is used as title, when there is no other title.
+func describe(title *string, author *string, comment *string, lines []string, titled bool) {
+	var comments []string
+	quoted := false
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		commented := strings.HasPrefix(line, ";")
+		if commented {
+			line = strings.TrimSpace(line[1:])
+		}
+		key, value := splitKey(line)
+		switch {
+		case line == "":
+		case key == "title":
+			*title = value
+			quoted = true
+		case key == "author":
+			*author = value
+		case key == "comment":
+			comments = append(comments, value)
+		case !commented && len(line) > 1 && line[0] == '\'' && line[len(line)-1] == '\'':
+			*title = line[1 : len(line)-1]
+			quoted = true
+		default:
+			comments = append(comments, line)
+		}
+	}
+	if titled && !quoted && len(comments) > 0 && *title == "" {
+		*title = comments[len(comments)-1]
+		comments = comments[:len(comments)-1]
+	}
+	for _, line := range comments {
+		if *comment != "" {
+			*comment += "\n"
+		}
+		*comment += line
+	}
+}
+
+// split a line like 'Author: name' into the lower case key and the value
+func splitKey(line string) (key string, value string) {
+	i := strings.Index(line, ":")
+	if i < 0 {
+		return
+	}
+	key = strings.ToLower(strings.TrimSpace(line[:i]))
+	switch key {
+	case "title", "author", "comment":
+		value = strings.TrimSpace(line[i+1:])
+		return
+	}
+	return "", ""
+}
@@ -0,0 +1,255 @@
+package level
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/g3force/Go_Sokoban/engine"
+)
+
+// keys of the meta data in .lev files
+const (
+	LEV_AUTHOR        = "autor"
+	LEV_VERSION       = "version"
+	LEV_TITLE         = "titel"
+	LEV_DESCRIPTION   = "discription"
+	LEV_VALIDATE_CODE = "validatecode"
+	LEV_SOLUTION      = "loesungsweg"
+)
+
+// the validatecode of a .lev file does not fit to its level and solution.
+// Some of the bundled levels were changed after their code was generated, so this is only a hint.
+type ValidateCodeError struct {
+	Code     string // code stored in the file
+	Computed string // code computed from the level and its solution
+}
+
+func (err *ValidateCodeError) Error() string {
+	return fmt.Sprintf("validatecode %s does not match the computed code %s", err.Code, err.Computed)
+}
+
+// error in the content of a .lev file
+type LevError struct {
+	Line    int // line within the file, starting at 1
+	Message string
+}
+
+func (err *LevError) Error() string {
+	return fmt.Sprintf("line %d: %s", err.Line, err.Message)
+}
+
+// load a level in .lev format from the specified file
+func LoadLev(filename string) (l Level, err error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	return ReadLev(f)
+}
+
+// read a level in .lev format. The meta data is given by lines like 'key=value',
+// the solution is given as list of the figure positions 'x,y;', starting with the initial position.
+// Lines with unknown keys are kept in Extra, empty lines and comment lines starting with '//' are ignored.
+// The validatecode is not checked, see CheckValidateCode.
+func ReadLev(r io.Reader) (l Level, err error) {
+	var solution string
+	solutionLine := 0
+	scanner := bufio.NewScanner(r)
+	scanner.Split(engine.ScanLines)
+	for nr := 1; scanner.Scan(); nr++ {
+		line := scanner.Text()
+		if nr == 1 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+		if strings.HasPrefix(line, "//") {
+			continue
+		}
+		if IsRow(line) {
+			rows, err := engine.ExpandRows(line)
+			if err != nil {
+				return l, &LevError{nr, err.Error()}
+			}
+			l.Rows = append(l.Rows, rows...)
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		i := strings.Index(line, "=")
+		if i < 0 {
+			return l, &LevError{nr, fmt.Sprintf("expected 'key=value', got %q", line)}
+		}
+		key, value := line[:i], line[i+1:]
+		switch key {
+		case LEV_AUTHOR:
+			l.Author = value
+		case LEV_VERSION:
+			l.Version = value
+		case LEV_TITLE:
+			l.Title = value
+		case LEV_DESCRIPTION:
+			l.Comment = value
+		case LEV_VALIDATE_CODE:
+			l.ValidateCode = value
+		case LEV_SOLUTION:
+			solution, solutionLine = value, nr
+		default:
+			l.Extra = append(l.Extra, line)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return
+	}
+	if len(l.Rows) == 0 {
+		return l, engine.ErrNoLevel
+	}
+	if l.Solution, err = parseLevSolution(solution, l.Rows); err != nil {
+		return l, &LevError{solutionLine, err.Error()}
+	}
+	return
+}
+
+// check the validatecode of the level, if it has one. Returns a ValidateCodeError, if the code does not match.
+func (l Level) CheckValidateCode() error {
+	if computed := l.ComputeValidateCode(); l.ValidateCode != "" && l.ValidateCode != computed {
+		return &ValidateCodeError{l.ValidateCode, computed}
+	}
+	return nil
+}
+
+// save the level in .lev format to the specified file
+func SaveLev(filename string, l Level) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return WriteLev(f, l)
+}
+
+// write the level in .lev format. The validatecode is computed from the level and its solution.
+// The lines with unknown keys are written after the known meta data.
+func WriteLev(w io.Writer, l Level) error {
+	solution, err := formatLevSolution(l.Solution, l.Rows)
+	if err != nil {
+		return err
+	}
+	b := bufio.NewWriter(w)
+	fmt.Fprintf(b, "%s=%s\n", LEV_AUTHOR, l.Author)
+	fmt.Fprintf(b, "%s=%s\n", LEV_VERSION, l.Version)
+	fmt.Fprintf(b, "%s=%s\n", LEV_TITLE, l.Title)
+	fmt.Fprintf(b, "%s=%s\n", LEV_DESCRIPTION, strings.Replace(l.Comment, "\n", " ", -1))
+	fmt.Fprintf(b, "%s=%s\n", LEV_VALIDATE_CODE, l.ComputeValidateCode())
+	for _, line := range l.Extra {
+		fmt.Fprintln(b, line)
+	}
+	fmt.Fprintln(b)
+	for _, row := range l.Rows {
+		fmt.Fprintln(b, row)
+	}
+	if len(l.Solution) > 0 {
+		fmt.Fprintf(b, "%s=%s\n", LEV_SOLUTION, solution)
+	}
+	return b.Flush()
+}
+
+// position of the figure within the rows
+func figurePosition(rows []string) (engine.Point, bool) {
+	for y, row := range rows {
+		if x := strings.IndexAny(row, "@+"); x >= 0 {
+			return engine.NewPoint(x, y), true
+		}
+	}
+	return engine.Point{}, false
+}
+
+// convert a list of figure positions like '6,5;6,4;' into directions
+func parseLevSolution(solution string, rows []string) (dirs []engine.Direction, err error) {
+	var positions []engine.Point
+	for _, pos := range strings.Split(solution, ";") {
+		if strings.TrimSpace(pos) == "" {
+			continue
+		}
+		coords := strings.Split(pos, ",")
+		if len(coords) != 2 {
+			return nil, fmt.Errorf("invalid position %q in solution", pos)
+		}
+		x, errX := strconv.Atoi(strings.TrimSpace(coords[0]))
+		y, errY := strconv.Atoi(strings.TrimSpace(coords[1]))
+		if errX != nil || errY != nil {
+			return nil, fmt.Errorf("invalid position %q in solution", pos)
+		}
+		positions = append(positions, engine.NewPoint(x, y))
+	}
+	if len(positions) == 0 {
+		return
+	}
+	if fig, ok := figurePosition(rows); !ok || fig != positions[0] {
+		return nil, fmt.Errorf("solution does not start at the figure")
+	}
+	for i := 1; i < len(positions); i++ {
+		dir := engine.PointDirection(engine.NewPoint(positions[i].X-positions[i-1].X, positions[i].Y-positions[i-1].Y))
+		if dir == engine.NO_DIRECTION {
+			return nil, fmt.Errorf("no single step from %d,%d to %d,%d in solution",
+				positions[i-1].X, positions[i-1].Y, positions[i].X, positions[i].Y)
+		}
+		dirs = append(dirs, dir)
+	}
+	return
+}
+
+// convert directions into a list of figure positions like '6,5;6,4;', starting at the figure
+func formatLevSolution(dirs []engine.Direction, rows []string) (string, error) {
+	if len(dirs) == 0 {
+		return "", nil
+	}
+	fig, ok := figurePosition(rows)
+	if !ok {
+		return "", fmt.Errorf("level has no figure")
+	}
+	solution := fmt.Sprintf("%d,%d;", fig.X, fig.Y)
+	for _, dir := range dirs {
+		fig = fig.Add(dir.Point())
+		solution += fmt.Sprintf("%d,%d;", fig.X, fig.Y)
+	}
+	return solution, nil
+}
+
+// compute the validatecode of the level and its solution. The code consists of the width, the height,
+// the number of walls, the number of points, the area, the number of boxes, an 'A' and the number of moves,
+// each written in its own base (5, 11, 20, 4, 12, 8 and 4), with every digit written as decimal number.
+// The moves are counted, the solution itself is not hashed. This reproduces the codes of 15 of the 21 bundled
+// levels with a code. The other levels have more or less walls than their code says or a shorter solution,
+// so they were most likely edited after the code was generated.
+func (l Level) ComputeValidateCode() string {
+	width, walls, points, boxes := 0, 0, 0, 0
+	for _, row := range l.Rows {
+		row = strings.TrimRight(row, " ")
+		if len(row) > width {
+			width = len(row)
+		}
+		walls += strings.Count(row, "#")
+		points += strings.Count(row, ".") + strings.Count(row, "*") + strings.Count(row, "+")
+		boxes += strings.Count(row, "$") + strings.Count(row, "*")
+	}
+	height := len(l.Rows)
+	return encodeDigits(width, 5) + encodeDigits(height, 11) + encodeDigits(walls, 20) + encodeDigits(points, 4) +
+		encodeDigits(width*height, 12) + encodeDigits(boxes, 8) + "A" + encodeDigits(len(l.Solution), 4)
+}
+
+// write n in the given base, every digit is written as decimal number
+func encodeDigits(n int, base int) string {
+	if n == 0 {
+		return "0"
+	}
+	code := ""
+	for ; n > 0; n /= base {
+		code = strconv.Itoa(n%base) + code
+	}
+	return code
+}
@@ -0,0 +1,482 @@
+package level
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/g3force/Go_Sokoban/engine"
+	"github.com/g3force/Go_Sokoban/internal/testlevel"
+)
+
+const collection = `; My collection
+Author: Somebody
+
+; 1
+
+#####
+#@$.#
+#####
+
+; 2
+'Duh!'
+; nice one
+
+ #######
+ #.$@$.#
+ #######
+Author: Somebody else
+`
+
+func TestReadCollection(t *testing.T) {
+	c, err := ReadCollection(strings.NewReader(collection))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Comment != "My collection" || c.Author != "Somebody" {
+		t.Errorf("wrong collection meta data: %q, %q", c.Comment, c.Author)
+	}
+	if len(c.Levels) != 2 {
+		t.Fatalf("expected 2 levels, got %d", len(c.Levels))
+	}
+	l := c.Levels[1]
+	if l.Title != "Duh!" || l.Author != "Somebody else" || l.Comment != "2\nnice one" || len(l.Rows) != 3 {
+		t.Errorf("wrong level: %q, %q, %q, %d rows", l.Title, l.Author, l.Comment, len(l.Rows))
+	}
+	for _, selector := range []string{"2", "Duh!", "duh!"} {
+		if l, err := c.Find(selector); err != nil || l.Title != "Duh!" {
+			t.Errorf("level %q not found", selector)
+		}
+	}
+	if l, err := c.Find("1"); err != nil || l.Title != "1" {
+		t.Error("level 1 not found")
+	}
+	if _, err := c.Find("3"); err == nil {
+		t.Error("found a level, that does not exist")
+	}
+	e := engine.NewEngine()
+	if err := l.Load(&e); err != nil || len(e.Boxes()) != 2 {
+		t.Errorf("level not loaded: %v", err)
+	}
+}
+
+func TestLoadCollection(t *testing.T) {
+	c, err := LoadCollection("../res/level/level-sets/Mac Levels.xsb.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Levels) != 197 || c.Levels[0].Title != "%Still More #1" {
+		t.Errorf("unexpected levels: %d, first %q", len(c.Levels), c.Levels[0].Title)
+	}
+	if _, err := ReadCollection(strings.NewReader("; nothing here\n")); err != ErrNoLevels {
+		t.Errorf("expected ErrNoLevels, got %v", err)
+	}
+}
+
+func TestLoadBundledCollections(t *testing.T) {
+	// levels, that can not be played, by their number
+	broken := map[string]map[int]bool{
+		// the figure stands in a gap of the outer wall
+		"Mac Levels.xsb.txt": {103: true, 104: true, 105: true, 106: true, 107: true, 108: true},
+		// more or less boxes than points
+		"Arcade deluxe.xsb.txt": {31: true, 45: true, 47: true},
+	}
+	// Microban and Howard's First Set end their lines with "\r" only
+	for file, n := range map[string]int{
+		"Mac Levels.xsb.txt":         197,
+		"Arcade deluxe.xsb.txt":      55,
+		"Microban.xsb.txt":           155,
+		"Howard's First Set.xsb.txt": 100,
+	} {
+		c, err := LoadCollection("../res/level/level-sets/" + file)
+		if err != nil {
+			t.Errorf("%s: %v", file, err)
+		} else if len(c.Levels) != n {
+			t.Errorf("%s: expected %d levels, got %d", file, n, len(c.Levels))
+		}
+		for i, l := range c.Levels {
+			e := engine.NewEngine()
+			if err := l.Load(&e); (err != nil) != broken[file][i+1] {
+				t.Errorf("%s, level %d: %v", file, i+1, err)
+			}
+		}
+	}
+	c, err := LoadCollection(testlevel.MicrobanFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := c.Find("95")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, expected := engine.NewEngine(), engine.NewEngine()
+	if err := l.Load(&e); err != nil {
+		t.Fatal(err)
+	}
+	testlevel.Load(t, &expected, testlevel.Microban(t, 95))
+	if e.XSB() != expected.XSB() || len(e.Symmetries()) != engine.SYMMETRIES {
+		t.Errorf("unexpected level 95 with %d symmetries:\n%s", len(e.Symmetries()), e.XSB())
+	}
+}
+
+func TestLineEndings(t *testing.T) {
+	for _, nl := range []string{"\n", "\r\n", "\r"} {
+		raw := strings.Replace(collection, "\n", nl, -1)
+		if c, err := ReadCollection(strings.NewReader(raw)); err != nil || len(c.Levels) != 2 || len(c.Levels[0].Rows) != 3 {
+			t.Errorf("collection with line ending %q not read: %v", nl, err)
+		}
+		lev := strings.Replace("titel=t\n#####\n#@$.#\n#####\nloesungsweg=1,1;2,1;\n", "\n", nl, -1)
+		if l, err := ReadLev(strings.NewReader(lev)); err != nil || l.Title != "t" || len(l.Rows) != 3 || len(l.Solution) != 1 {
+			t.Errorf("level with line ending %q not read: %v", nl, err)
+		}
+		e := engine.NewEngine()
+		testlevel.Load(t, &e, strings.Replace(testlevel.Corridor, "\n", nl, -1))
+		if len(e.Surface) != 4 {
+			t.Errorf("level with line ending %q has %d rows", nl, len(e.Surface))
+		}
+	}
+}
+
+// read the .lev file and load its level into a new engine, stop the test on errors
+func loadLevFile(t *testing.T, filename string) (Level, engine.Engine) {
+	t.Helper()
+	l, err := LoadLev(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := engine.NewEngine()
+	if err := l.Load(&e); err != nil {
+		t.Fatal(err)
+	}
+	return l, e
+}
+
+func TestLoadLev(t *testing.T) {
+	l, err := LoadLev("../res/level/level_000.lev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Author != "Nicolai Ommer" || l.Version != "1.0" || l.Title != "very easy" ||
+		l.Comment != "Dies ist eigentlich nur ein Test-Level." || l.ValidateCode != "136162402A32" || len(l.Rows) != 6 {
+		t.Errorf("unexpected meta data: %+v", l)
+	}
+	if len(l.Solution) != 12 || l.Solution[0] != engine.Direction(3) {
+		t.Fatalf("unexpected solution: %v", l.Solution)
+	}
+	// the outer wall of level_000 has gaps, so it can only be read, but not played
+	e := engine.NewEngine()
+	if err := l.Load(&e); err == nil {
+		t.Error("open level loaded")
+	}
+	solved, e := loadLevFile(t, "../res/level/level_002.lev")
+	for _, dir := range solved.Solution {
+		e.Move(dir)
+	}
+	if !e.Won() {
+		t.Error("solution does not solve the level")
+	}
+
+	var b bytes.Buffer
+	if err := WriteLev(&b, l); err != nil {
+		t.Fatal(err)
+	}
+	written, err := ReadLev(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sameLevel(l, written) || len(written.Solution) != len(l.Solution) || written.CheckValidateCode() != nil {
+		t.Errorf("written level differs: %+v", written)
+	}
+
+	// levels with a matching code are written unchanged
+	l, err = LoadLev("../res/level/level_002.lev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Reset()
+	if err := WriteLev(&b, l); err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := ioutil.ReadFile("../res/level/level_002.lev")
+	if b.String() != strings.Replace(string(raw), "\r", "", -1) {
+		t.Errorf("written level differs:\n%s", b.String())
+	}
+
+	// unknown keys like the image directory are kept
+	l, err = LoadLev("../res/level/level_004.lev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Reset()
+	if err := WriteLev(&b, l); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "\ndir=25\n") {
+		t.Errorf("unknown key not written:\n%s", b.String())
+	}
+	if written, err = ReadLev(&b); err != nil || len(written.Extra) != 1 || written.Extra[0] != "dir=25" {
+		t.Errorf("unexpected unknown keys %q: %v", written.Extra, err)
+	}
+}
+
+func TestLoadBundledLevels(t *testing.T) {
+	files, err := filepath.Glob("../res/level/*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		if info, err := os.Stat(file); err != nil || info.IsDir() {
+			continue
+		}
+		l, err := LoadLev(file)
+		if err != nil {
+			t.Errorf("%s: %v", file, err)
+			continue
+		}
+		e := engine.NewEngine()
+		// level_000 has gaps in its outer wall
+		if err := l.Load(&e); (err != nil) != (filepath.Base(file) == "level_000.lev") {
+			t.Errorf("%s: %v", file, err)
+		}
+	}
+}
+
+func TestValidateCode(t *testing.T) {
+	for _, file := range []string{"level_002.lev", "level_004.lev", "level_009.lev", "level_021.lev"} {
+		l, err := LoadLev("../res/level/" + file)
+		if err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		if err := l.CheckValidateCode(); err != nil {
+			t.Errorf("%s: %v", file, err)
+		}
+	}
+	l, _ := LoadLev("../res/level/level_002.lev")
+	l.Solution = l.Solution[:10]
+	err := l.CheckValidateCode()
+	if vErr, ok := err.(*ValidateCodeError); !ok || vErr.Code != "1071222112A30" || vErr.Computed != "1071222112A22" {
+		t.Errorf("expected a ValidateCodeError, got %v", err)
+	}
+	l.ValidateCode = ""
+	if err := l.CheckValidateCode(); err != nil {
+		t.Errorf("level without code not accepted: %v", err)
+	}
+}
+
+func TestLoadLevComments(t *testing.T) {
+	// the description of the format is a valid .lev file with comment lines
+	l, _ := loadLevFile(t, "../res/level/leveldatei-aufbau.txt")
+	if l.Title != "Labyrint" || l.Author != "Nicolai Ommer" || len(l.Rows) != 12 {
+		t.Errorf("unexpected level %q by %q with %d rows", l.Title, l.Author, len(l.Rows))
+	}
+}
+
+func TestReadLevError(t *testing.T) {
+	for _, raw := range []string{
+		"foo\n#####\n#@$.#\n#####\n",
+		"#####\n#@$.#\n#####\nloesungsweg=1,1;3,1;\n",
+		"#####\n#@$.#\n#####\nloesungsweg=2,1;3,1;\n",
+		"#####\n#@$.#\n#####\nloesungsweg=1,1;x;\n",
+	} {
+		if _, err := ReadLev(strings.NewReader(raw)); err == nil {
+			t.Errorf("no error for %q", raw)
+		}
+	}
+}
+
+const slc = `<?xml version="1.0" encoding="ISO-8859-1"?>
+<SokobanLevels>
+  <Title>Small ones</Title>
+  <Description>
+    Two levels
+    for testing
+  </Description>
+  <LevelCollection Copyright="Somebody" MaxWidth="9" MaxHeight="3">
+    <Level Id="First" Width="5" Height="3">
+      <L>#####</L>
+      <L>#@$.#</L>
+      <L>#####</L>
+    </Level>
+    <Level Id="Zweite Stra` + "\xdf" + `e" Copyright="Somebody else" Width="9" Height="3">
+      <L> #######</L>
+      <L> #.$@$.#</L>
+      <L> #######</L>
+    </Level>
+  </LevelCollection>
+</SokobanLevels>
+`
+
+func TestReadSLC(t *testing.T) {
+	c, err := ReadSLC(strings.NewReader(slc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Title != "Small ones" || c.Author != "Somebody" || c.Comment != "Two levels\nfor testing" {
+		t.Errorf("wrong collection meta data: %q, %q, %q", c.Title, c.Author, c.Comment)
+	}
+	if len(c.Levels) != 2 {
+		t.Fatalf("expected 2 levels, got %d", len(c.Levels))
+	}
+	l := c.Levels[1]
+	if l.Title != "Zweite Straße" || l.Author != "Somebody else" || len(l.Rows) != 3 || l.Rows[1] != " #.$@$.#" {
+		t.Errorf("wrong level: %q, %q, %q", l.Title, l.Author, l.Rows)
+	}
+}
+
+func TestCollectionRoundTrip(t *testing.T) {
+	c, err := ReadSLC(strings.NewReader(slc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Levels[0].Comment = "easy\nvery easy"
+	var b bytes.Buffer
+	if err := WriteCollection(&b, c); err != nil {
+		t.Fatal(err)
+	}
+	xsb, err := ReadCollection(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sameCollection(c, xsb) {
+		t.Errorf("collection changed by XSB:\n%+v\n%+v", c, xsb)
+	}
+
+	c.Levels[0].Comment = ""
+	if err := WriteSLC(&b, c); err != nil {
+		t.Fatal(err)
+	}
+	written, err := ReadSLC(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sameCollection(c, written) {
+		t.Errorf("collection changed by SLC:\n%+v\n%+v", c, written)
+	}
+}
+
+func sameCollection(a Collection, b Collection) bool {
+	if a.Title != b.Title || a.Author != b.Author || a.Comment != b.Comment || len(a.Levels) != len(b.Levels) {
+		return false
+	}
+	for i := range a.Levels {
+		if !sameLevel(a.Levels[i], b.Levels[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// compare the meta data and the rows of two levels
+func sameLevel(a Level, b Level) bool {
+	return a.Title == b.Title && a.Author == b.Author && a.Comment == b.Comment &&
+		strings.Join(a.Rows, "\n") == strings.Join(b.Rows, "\n")
+}
+
+func TestCollectionRLE(t *testing.T) {
+	c, err := ReadCollection(strings.NewReader(collection))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err := WriteCollectionRLE(&b, c); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "\n-7#|-#.$@$.#|-7#\n") {
+		t.Errorf("level not run length encoded:\n%s", b.String())
+	}
+	rle, err := ReadCollection(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sameCollection(c, rle) {
+		t.Errorf("collection changed by RLE:\n%+v\n%+v", c, rle)
+	}
+}
+
+func TestSetComment(t *testing.T) {
+	c, err := ReadCollection(strings.NewReader(collection))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := &c.Levels[0]
+	comment := l.Comment
+	l.SetComment("Difficulty", "1.00")
+	l.SetComment("Difficulty", "2.00")
+	if want := strings.TrimPrefix(comment+"\nDifficulty: 2.00", "\n"); l.Comment != want {
+		t.Errorf("unexpected comment %q, expected %q", l.Comment, want)
+	}
+	var b bytes.Buffer
+	if err := WriteCollection(&b, c); err != nil {
+		t.Fatal(err)
+	}
+	annotated, err := ReadCollection(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sameCollection(c, annotated) {
+		t.Errorf("annotation lost:\n%+v\n%+v", c, annotated)
+	}
+}
+
+func TestSession(t *testing.T) {
+	l, e := loadLevFile(t, "../res/level/level_002.lev")
+	for _, dir := range l.Solution[:5] {
+		e.Move(dir)
+	}
+	dir, err := ioutil.TempDir("", "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "session.json")
+	if err := SaveSession(filename, NewSession(&e, "level_002.lev", "")); err != nil {
+		t.Fatal(err)
+	}
+	s, err := LoadSession(filename)
+	if err != nil || s.Level != "level_002.lev" || s.MoveCount != 5 {
+		t.Fatalf("unexpected session %+v: %v", s, err)
+	}
+	resumed := engine.NewEngine()
+	l.Load(&resumed)
+	if err := s.Replay(&resumed); err != nil {
+		t.Fatal(err)
+	}
+	if resumed.XSB() != e.XSB() || len(resumed.History) != 5 {
+		t.Errorf("session not resumed:\n%s", resumed.XSB())
+	}
+	resumed.UndoStep()
+	if resumed.HistoryLURD() != e.HistoryLURD()[:4] {
+		t.Errorf("undo after resuming failed: %s", resumed.HistoryLURD())
+	}
+
+	s.MoveCount = 4
+	if err := s.Replay(&resumed); err == nil {
+		t.Error("wrong move count not detected")
+	}
+
+	// a game state with history, that was played on
+	raw, err := json.Marshal(&e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := filepath.Join(dir, "state.json")
+	ioutil.WriteFile(state, raw, 0644)
+	for _, dir := range l.Solution[5:8] {
+		e.Move(dir)
+	}
+	s = NewSession(&e, state, "")
+	resumed = engine.NewEngine()
+	if err := json.Unmarshal(raw, &resumed); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Replay(&resumed); err != nil {
+		t.Fatal(err)
+	}
+	if resumed.XSB() != e.XSB() || resumed.HistoryLURD() != e.HistoryLURD() {
+		t.Errorf("session of a game state not resumed: %s\n%s", resumed.HistoryLURD(), resumed.XSB())
+	}
+}
@@ -0,0 +1,70 @@
+package level
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/g3force/Go_Sokoban/engine"
+)
+
+// version of the session format, increased on every incompatible change
+const SESSION_VERSION = 1
+
+// a game in progress, that can be saved and resumed later
+type Session struct {
+	Version   int    `json:"version"`
+	Level     string `json:"level"`              // file of the level
+	Selector  string `json:"selector,omitempty"` // number or title of the level within a collection
+	Moves     string `json:"moves"`              // all moves in LURD notation
+	MoveCount int    `json:"moveCount"`
+}
+
+// create a session for the level in the given file with all moves within the history of the engine
+func NewSession(e *engine.Engine, filename string, selector string) Session {
+	return Session{SESSION_VERSION, filename, selector, e.HistoryLURD(), len(e.History)}
+}
+
+// load a session from the specified file
+func LoadSession(filename string) (s Session, err error) {
+	raw, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return
+	}
+	if err = json.Unmarshal(raw, &s); err != nil {
+		return
+	}
+	if s.Version < 1 || s.Version > SESSION_VERSION {
+		err = fmt.Errorf("unsupported version %d of the session format", s.Version)
+	}
+	return
+}
+
+// save the session to the specified file
+func SaveSession(filename string, s Session) error {
+	raw, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, append(raw, '\n'), 0644)
+}
+
+// replay the moves of the session through the engine, that has the level of the session loaded.
+// The engine is restarted first, as the session contains all moves from the initial position,
+// also those of a game state with history. Afterwards, the history of the engine contains all moves.
+func (s Session) Replay(e *engine.Engine) error {
+	dirs, err := engine.ParseLURD(s.Moves)
+	if err != nil {
+		return err
+	}
+	if len(dirs) != s.MoveCount {
+		return fmt.Errorf("session has %d moves, but a move count of %d", len(dirs), s.MoveCount)
+	}
+	e.Restart()
+	for i, dir := range dirs {
+		if moved, _ := e.Move(dir); !moved {
+			return &engine.MoveError{Step: i + 1, Dir: dir}
+		}
+	}
+	return nil
+}
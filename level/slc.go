@@ -0,0 +1,146 @@
+package level
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// root element of a collection in SLC format
+type slcFile struct {
+	XMLName     xml.Name      `xml:"SokobanLevels"`
+	Title       string        `xml:"Title"`
+	Description string        `xml:"Description"`
+	Email       string        `xml:"Email,omitempty"`
+	Url         string        `xml:"Url,omitempty"`
+	Collection  slcCollection `xml:"LevelCollection"`
+}
+
+type slcCollection struct {
+	Copyright string     `xml:"Copyright,attr"`
+	MaxWidth  int        `xml:"MaxWidth,attr,omitempty"`
+	MaxHeight int        `xml:"MaxHeight,attr,omitempty"`
+	Levels    []slcLevel `xml:"Level"`
+}
+
+type slcLevel struct {
+	Id        string   `xml:"Id,attr"`
+	Width     int      `xml:"Width,attr,omitempty"`
+	Height    int      `xml:"Height,attr,omitempty"`
+	Copyright string   `xml:"Copyright,attr,omitempty"`
+	Rows      []string `xml:"L"`
+}
+
+// load a collection of levels in SLC format from the specified file
+func LoadSLC(filename string) (c Collection, err error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	return ReadSLC(f)
+}
+
+// read a collection of levels in the XML based SLC format.
+// The copyright of the collection and of the levels is used as author, the id of a level as title.
+func ReadSLC(r io.Reader) (c Collection, err error) {
+	var slc slcFile
+	decoder := xml.NewDecoder(r)
+	decoder.CharsetReader = charsetReader
+	if err = decoder.Decode(&slc); err != nil {
+		return
+	}
+	c.Title = strings.TrimSpace(slc.Title)
+	c.Author = strings.TrimSpace(slc.Collection.Copyright)
+	c.Comment = trimLines(slc.Description)
+	for _, sl := range slc.Collection.Levels {
+		if len(sl.Rows) == 0 {
+			continue
+		}
+		l := Level{Title: strings.TrimSpace(sl.Id), Author: strings.TrimSpace(sl.Copyright), Rows: sl.Rows}
+		c.Levels = append(c.Levels, l)
+	}
+	if len(c.Levels) == 0 {
+		err = ErrNoLevels
+	}
+	return
+}
+
+// save the collection in SLC format to the specified file
+func SaveSLC(filename string, c Collection) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return WriteSLC(f, c)
+}
+
+// write the collection in SLC format. Levels without title get their number as id.
+// Comments of single levels can not be written in this format.
+func WriteSLC(w io.Writer, c Collection) error {
+	slc := slcFile{Title: c.Title, Description: c.Comment}
+	slc.Collection.Copyright = c.Author
+	for i, l := range c.Levels {
+		sl := slcLevel{Id: l.Title, Height: len(l.Rows), Rows: l.Rows}
+		if sl.Id == "" {
+			sl.Id = fmt.Sprint(i + 1)
+		}
+		if l.Author != c.Author {
+			sl.Copyright = l.Author
+		}
+		for _, row := range l.Rows {
+			if len(row) > sl.Width {
+				sl.Width = len(row)
+			}
+		}
+		if sl.Width > slc.Collection.MaxWidth {
+			slc.Collection.MaxWidth = sl.Width
+		}
+		if sl.Height > slc.Collection.MaxHeight {
+			slc.Collection.MaxHeight = sl.Height
+		}
+		slc.Collection.Levels = append(slc.Collection.Levels, sl)
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(slc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// remove leading and trailing whitespace of all lines and empty lines at the beginning and the end
+func trimLines(text string) string {
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	for i := range lines {
+		lines[i] = strings.TrimSpace(lines[i])
+	}
+	return strings.Join(lines, "\n")
+}
+
+// SLC files are often encoded in ISO-8859-1, which is converted to UTF-8
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(charset) {
+	case "iso-8859-1", "latin1", "windows-1252":
+		raw, err := ioutil.ReadAll(input)
+		if err != nil {
+			return nil, err
+		}
+		converted := make([]rune, len(raw))
+		for i, b := range raw {
+			converted[i] = rune(b)
+		}
+		return strings.NewReader(string(converted)), nil
+	case "utf-8":
+		return input, nil
+	}
+	return nil, fmt.Errorf("unsupported charset %s", charset)
+}
@@ -2,3 +2,5 @@
 
 go test github.com/g3force/Go_Sokoban/ai
 go test github.com/g3force/Go_Sokoban/engine
+go test github.com/g3force/Go_Sokoban/generator
+go test github.com/g3force/Go_Sokoban/level